@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// AssigneeRules says what to check about who open issues are assigned
+// to.
+type AssigneeRules struct {
+	// Report open issues with this label that nobody is assigned to, if
+	// not empty
+	UrgentLabel string
+
+	// Report people assigned to more than this many open issues, if not
+	// zero
+	MaxAssigned int
+
+	// Report issues assigned to people who aren't in the org any more
+	// (which includes outside collaborators)
+	CheckDeparted bool
+
+	// Unassign people who aren't in the org any more, and put issues
+	// that leaves with nobody assigned back into triage. Needs
+	// CheckDeparted.
+	UnassignDeparted bool
+}
+
+// Check who open issues are assigned to, as configured. openIssues are
+// the repo#number strings of all the open issues and pull requests in
+// the org, which knownIssues already has.
+func checkAssignees(ctx context.Context, client *gh.Client, rules AssigneeRules, openIssues map[string]struct{}, knownIssues *issueCache, dryRun bool) {
+	fmt.Printf("### CHECKING ASSIGNEES\n")
+
+	tags := []string{}
+	for tag, _ := range openIssues {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var members map[string]struct{}
+	if rules.CheckDeparted {
+		members = listOrgMembers(ctx, client)
+	}
+
+	// Who has what assigned to them
+	assigned := map[string][]string{}
+
+	for _, tag := range tags {
+		issue := knownIssues.issues[tag]
+		if issue.IsPullRequest() {
+			continue
+		}
+
+		if rules.UrgentLabel != "" && len(issue.Assignees) == 0 {
+			for _, l := range issue.Labels {
+				if l.GetName() == rules.UrgentLabel {
+					fmt.Printf("Issue %s is %s, but nobody is assigned to it: %s\n", tag, rules.UrgentLabel, issue.GetTitle())
+				}
+			}
+		}
+
+		departed := []string{}
+		for _, u := range issue.Assignees {
+			login := u.GetLogin()
+			assigned[login] = append(assigned[login], tag)
+			if _, member := members[login]; rules.CheckDeparted && !member {
+				departed = append(departed, login)
+			}
+		}
+		if len(departed) == 0 {
+			continue
+		}
+
+		fmt.Printf("Issue %s is assigned to %s, who aren't in the org any more\n", tag, strings.Join(departed, ", "))
+		if !rules.UnassignDeparted {
+			continue
+		}
+
+		repo, number, err := utils.ParseIssueTag(tag)
+		if err != nil {
+			fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Printf("ACTION: Unassigning %s from %s\n", strings.Join(departed, ", "), tag)
+		if !dryRun {
+			_, resp, err := client.Issues.RemoveAssignees(ctx, GITHUB_ORG_NAME, repo, number, departed)
+			if err != nil {
+				fmt.Printf("Error unassigning issue: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+
+		if len(departed) == len(issue.Assignees) {
+			moveToTriage(ctx, client, tag, issue, dryRun)
+		}
+	}
+
+	if rules.MaxAssigned > 0 {
+		logins := []string{}
+		for login, _ := range assigned {
+			logins = append(logins, login)
+		}
+		sort.Strings(logins)
+
+		for _, login := range logins {
+			if len(assigned[login]) > rules.MaxAssigned {
+				fmt.Printf("%s has %d open issues assigned, more than %d: %s\n", login, len(assigned[login]), rules.MaxAssigned, strings.Join(assigned[login], ", "))
+			}
+		}
+	}
+}
+
+// Put an issue's card back in the triage column, or give it one there if
+// it isn't on the triage column's board.
+func moveToTriage(ctx context.Context, client *gh.Client, tag string, issue *gh.Issue, dryRun bool) {
+	moveToColumn(ctx, client, tag, issue, GITHUB_TRIAGE_COLUMN, dryRun)
+}
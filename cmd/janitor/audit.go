@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// Things that can be wrong with a card, in the order we report them
+var cardProblems = []string{
+	"Note cards",
+	"Cards for issues in other orgs",
+	"Cards for issues in archived repos",
+	"Cards for deleted or inaccessible issues",
+	"Cards for issues that have been transferred",
+}
+
+// Look over every card on every board, and list the ones that don't
+// point at an issue we can use. With -archive, archive them too.
+func auditBoards(args []string) {
+	fs := flag.NewFlagSet("audit-boards", flag.ExitOnError)
+	archive := fs.Bool("archive", false, "archive the cards that are found")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	client := newGithubClient(ctx)
+
+	archivedRepos := map[string]struct{}{}
+	projects := listOrgProjects(ctx, client)
+	for _, repo := range listOrgRepos(ctx, client) {
+		if *repo.Archived {
+			archivedRepos[*(repo.Name)] = struct{}{}
+		} else {
+			projects = append(projects, listRepoProjects(ctx, client, *(repo.Name))...)
+		}
+	}
+
+	// Descriptions of broken cards, grouped by what's wrong with them
+	problems := map[string][]string{}
+	brokenCards := []*gh.ProjectCard{}
+
+	for _, project := range projects {
+		pn := *(project.Name)
+		fmt.Printf("### AUDITING PROJECT: %s\n", pn)
+
+		for _, column := range listProjectColumns(ctx, client, *(project.ID)) {
+			where := fmt.Sprintf("%s/%s", pn, *(column.Name))
+
+			for _, card := range listColumnCards(ctx, client, *(column.ID)) {
+				problem, description := auditCard(ctx, client, card, archivedRepos)
+				if problem == "" {
+					continue
+				}
+				problems[problem] = append(problems[problem], fmt.Sprintf("%s: %s", where, description))
+				brokenCards = append(brokenCards, card)
+			}
+		}
+	}
+
+	for _, problem := range cardProblems {
+		if len(problems[problem]) == 0 {
+			continue
+		}
+		fmt.Printf("### %s (%d)\n", problem, len(problems[problem]))
+		for _, description := range problems[problem] {
+			fmt.Printf("- %s\n", description)
+		}
+	}
+
+	if *archive {
+		if DRY_RUN {
+			fmt.Printf("DRY RUN MODE - not actually archiving anything!\n")
+		}
+		for _, card := range brokenCards {
+			fmt.Printf("ACTION: Archiving card %d\n", *(card.ID))
+			if !DRY_RUN {
+				resp, err := archiveProjectCard(ctx, client, *(card.ID))
+				if err != nil {
+					fmt.Printf("Error archiving card: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+		}
+	}
+
+	fmt.Printf("Done.\n")
+}
+
+// Work out what's wrong with a card, if anything, returning one of
+// cardProblems and a description of the card, or empty strings if it's
+// fine.
+func auditCard(ctx context.Context, client *gh.Client, card *gh.ProjectCard, archivedRepos map[string]struct{}) (string, string) {
+	if card.ContentURL == nil {
+		note := ""
+		if card.Note != nil {
+			note = strings.SplitN(*(card.Note), "\n", 2)[0]
+		}
+		return cardProblems[0], fmt.Sprintf("%q", note)
+	}
+
+	org, repo, number, err := utils.ParseContentURL(*(card.ContentURL))
+	if err != nil {
+		fmt.Printf("Error parsing card content: %s\n", err.Error())
+		os.Exit(1)
+	}
+	tag := fmt.Sprintf("%s#%d", repo, number)
+
+	if org != GITHUB_ORG_NAME {
+		return cardProblems[1], fmt.Sprintf("%s/%s", org, tag)
+	}
+
+	if _, archived := archivedRepos[repo]; archived {
+		return cardProblems[2], tag
+	}
+
+	// Fetching an issue that has been transferred follows the redirect to
+	// wherever it is now
+	issue, resp, err := client.Issues.Get(ctx, org, repo, number)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusForbidden) {
+			return cardProblems[3], fmt.Sprintf("%s (%s)", tag, resp.Status)
+		}
+		fmt.Printf("Error fetching issue %s: %+v\n", tag, err)
+		os.Exit(1)
+	}
+	waitForRateLimit(resp)
+
+	if issue.RepositoryURL != nil && !strings.HasSuffix(strings.ToLower(*(issue.RepositoryURL)), strings.ToLower("/repos/"+org+"/"+repo)) {
+		return cardProblems[4], fmt.Sprintf("%s is now %s", tag, *(issue.HTMLURL))
+	}
+
+	return "", ""
+}
+
+// go-github doesn't know how to archive cards, so we ask for it by hand
+func archiveProjectCard(ctx context.Context, client *gh.Client, cardID int64) (*gh.Response, error) {
+	req, err := client.NewRequest("PATCH", fmt.Sprintf("projects/columns/cards/%d", cardID), map[string]bool{
+		"archived": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.inertia-preview+json")
+	return client.Do(ctx, req, nil)
+}
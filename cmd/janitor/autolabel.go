@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// LabelRule adds Label to open issues that meet all of its conditions;
+// conditions left empty always hold. Labels are only ever added, never
+// removed: if somebody takes the label off again, the janitor leaves it
+// off.
+type LabelRule struct {
+	// Must be in DESIRED_LABELS, so the janitor only adds labels it
+	// looks after
+	Label string
+
+	// Regular expressions the title or body must match
+	TitleMatches string
+	BodyMatches  string
+
+	// Slug of a team in the org the author must be in
+	AuthorTeam string
+
+	// Repos the issue must be in one of
+	Repos []string
+
+	// File name of an issue template in the repo's .github/ISSUE_TEMPLATE
+	// that the issue must have been written from, going by its headings
+	Template string
+}
+
+// Things about the org and its repos the rules need, fetched once
+type labelRuleState struct {
+	titleRegexps map[int]*regexp.Regexp
+	bodyRegexps  map[int]*regexp.Regexp
+
+	// Team members by team slug
+	teams map[string]map[string]struct{}
+
+	// Issue templates by repo and file name; "" for ones that don't exist
+	templates map[string]string
+}
+
+var labelRules *labelRuleState
+
+// Check the rules in LABEL_RULES make sense, and compile them
+func compileLabelRules() *labelRuleState {
+	state := &labelRuleState{
+		titleRegexps: map[int]*regexp.Regexp{},
+		bodyRegexps:  map[int]*regexp.Regexp{},
+		teams:        map[string]map[string]struct{}{},
+		templates:    map[string]string{},
+	}
+
+	for idx, rule := range LABEL_RULES {
+		if _, owned := DESIRED_LABELS[rule.Label]; !owned {
+			fmt.Printf("Error: label rule %d adds %q, which isn't in DESIRED_LABELS\n", idx, rule.Label)
+			os.Exit(1)
+		}
+
+		var err error
+		if rule.TitleMatches != "" {
+			state.titleRegexps[idx], err = regexp.Compile(rule.TitleMatches)
+		}
+		if err == nil && rule.BodyMatches != "" {
+			state.bodyRegexps[idx], err = regexp.Compile(rule.BodyMatches)
+		}
+		if err != nil {
+			fmt.Printf("Error in label rule %d: %s\n", idx, err.Error())
+			os.Exit(1)
+		}
+	}
+
+	return state
+}
+
+func (s *labelRuleState) teamMembers(ctx context.Context, client *gh.Client, slug string) map[string]struct{} {
+	if members, known := s.teams[slug]; known {
+		return members
+	}
+
+	var team *gh.Team
+	opt := &gh.ListOptions{PerPage: 100}
+	for team == nil {
+		teams, resp, err := client.Organizations.ListTeams(ctx, GITHUB_ORG_NAME, opt)
+		if err != nil {
+			fmt.Printf("Error fetching teams: %+v\n", err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		for _, t := range teams {
+			if t.GetSlug() == slug {
+				team = t
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	if team == nil {
+		fmt.Printf("There's no team %s in %s, so nobody is in it\n", slug, GITHUB_ORG_NAME)
+		s.teams[slug] = map[string]struct{}{}
+		return s.teams[slug]
+	}
+
+	members := map[string]struct{}{}
+	memberOpt := &gh.OrganizationListTeamMembersOptions{
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		users, resp, err := client.Organizations.ListTeamMembers(ctx, team.GetID(), memberOpt)
+		if err != nil {
+			fmt.Printf("Error fetching members of team %s: %+v\n", slug, err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		for _, u := range users {
+			members[u.GetLogin()] = struct{}{}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		memberOpt.Page = resp.NextPage
+	}
+
+	s.teams[slug] = members
+	return members
+}
+
+func (s *labelRuleState) template(ctx context.Context, client *gh.Client, repo, name string) string {
+	key := repo + "/" + name
+	if template, known := s.templates[key]; known {
+		return template
+	}
+
+	template := ""
+	file, _, resp, err := client.Repositories.GetContents(ctx, GITHUB_ORG_NAME, repo, ".github/ISSUE_TEMPLATE/"+name, nil)
+	if err != nil {
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			fmt.Printf("Error fetching issue template %s in %s: %+v\n", name, repo, err)
+			os.Exit(1)
+		}
+	} else {
+		waitForRateLimit(resp)
+		template, err = file.GetContent()
+		if err != nil {
+			fmt.Printf("Error decoding issue template %s in %s: %s\n", name, repo, err.Error())
+			os.Exit(1)
+		}
+	}
+
+	s.templates[key] = template
+	return template
+}
+
+// Work out whether an issue meets a rule's conditions, and if so, why
+func (s *labelRuleState) matches(ctx context.Context, client *gh.Client, idx int, repo string, issue *gh.Issue) (bool, []string) {
+	rule := LABEL_RULES[idx]
+	reasons := []string{}
+
+	if len(rule.Repos) > 0 {
+		inRepo := false
+		for _, r := range rule.Repos {
+			if r == repo {
+				inRepo = true
+			}
+		}
+		if !inRepo {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("it's in %s", repo))
+	}
+
+	if re, ok := s.titleRegexps[idx]; ok {
+		if !re.MatchString(issue.GetTitle()) {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("its title matches `%s`", rule.TitleMatches))
+	}
+
+	if re, ok := s.bodyRegexps[idx]; ok {
+		if !re.MatchString(issue.GetBody()) {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("its description matches `%s`", rule.BodyMatches))
+	}
+
+	if rule.AuthorTeam != "" {
+		author := issue.GetUser().GetLogin()
+		if _, member := s.teamMembers(ctx, client, rule.AuthorTeam)[author]; !member {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s is in the %s team", author, rule.AuthorTeam))
+	}
+
+	if rule.Template != "" {
+		template := s.template(ctx, client, repo, rule.Template)
+		if template == "" || !utils.UsesTemplate(issue.GetBody(), template) {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("it was written from the %s template", rule.Template))
+	}
+
+	return true, reasons
+}
+
+// Whether somebody other than the janitor has taken a label off an
+// issue before
+func labelRemovedBefore(ctx context.Context, client *gh.Client, repo string, number int, label string) bool {
+	me := getJanitorLogin(ctx, client)
+
+	opt := &gh.ListOptions{PerPage: 100}
+	for {
+		events, resp, err := client.Issues.ListIssueEvents(ctx, GITHUB_ORG_NAME, repo, number, opt)
+		if err != nil {
+			fmt.Printf("Error fetching events on %s#%d: %+v\n", repo, number, err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		for _, e := range events {
+			if e.GetEvent() == "unlabeled" && e.Label.GetName() == label && e.GetActor().GetLogin() != me {
+				return true
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return false
+}
+
+// A label the janitor has added, and why
+type autoLabel struct {
+	Label string
+	Why   string
+}
+
+// Add the labels from LABEL_RULES that an open issue should have, and
+// leave a comment saying why. The labels are added to issue too, so
+// later checks see them.
+func applyLabelRules(ctx context.Context, client *gh.Client, repo string, issue *gh.Issue, dryRun bool) {
+	if len(LABEL_RULES) == 0 || issue.IsPullRequest() {
+		return
+	}
+	if labelRules == nil {
+		labelRules = compileLabelRules()
+	}
+
+	tag := fmt.Sprintf("%s#%d", repo, issue.GetNumber())
+
+	has := map[string]struct{}{}
+	for _, l := range issue.Labels {
+		has[l.GetName()] = struct{}{}
+	}
+
+	added := []autoLabel{}
+	for idx, rule := range LABEL_RULES {
+		if _, already := has[rule.Label]; already {
+			continue
+		}
+
+		match, reasons := labelRules.matches(ctx, client, idx, repo, issue)
+		if !match {
+			continue
+		}
+
+		if labelRemovedBefore(ctx, client, repo, issue.GetNumber(), rule.Label) {
+			fmt.Printf("Not labelling %s as %s again, as somebody took it off\n", tag, rule.Label)
+			continue
+		}
+
+		why := strings.Join(reasons, " and ")
+		if why == "" {
+			why = "every issue gets it"
+		}
+		has[rule.Label] = struct{}{}
+		added = append(added, autoLabel{rule.Label, why})
+	}
+
+	if len(added) == 0 {
+		return
+	}
+
+	// Don't add labels without saying why. Each label gets its own
+	// comment, so the reasons for earlier ones aren't lost; they're all
+	// worked out before any are posted, so posting one doesn't hold the
+	// rest up until the cooldown is over.
+	explanations := []*pendingComment{}
+	for _, al := range added {
+		explanation := prepareComment(ctx, client, repo, issue.GetNumber(), "autolabel "+al.Label, commentTemplate("auto-labelled"), struct {
+			Labels []autoLabel
+		}{[]autoLabel{al}}, false)
+		if explanation == nil {
+			return
+		}
+		explanations = append(explanations, explanation)
+	}
+
+	for idx, al := range added {
+		fmt.Printf("ACTION: Labelling %s as %s, because %s\n", tag, al.Label, al.Why)
+		if !dryRun {
+			_, resp, err := client.Issues.AddLabelsToIssue(ctx, GITHUB_ORG_NAME, repo, issue.GetNumber(), []string{al.Label})
+			if err != nil {
+				fmt.Printf("Error labelling issue: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+
+		name := al.Label
+		issue.Labels = append(issue.Labels, gh.Label{Name: &name})
+
+		explanations[idx].post(ctx, client, dryRun)
+	}
+}
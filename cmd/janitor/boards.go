@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// BoardRules say which columns of a classic project board cards should
+// live in, given the state of their issue or pull request. Columns are
+// given by name; leaving one empty disables the rules that move cards
+// into it.
+type BoardRules struct {
+	// Closed issues and merged pull requests go here
+	DoneColumn string
+
+	// Issues that are open again, but whose cards are still in
+	// DoneColumn, go back here
+	TodoColumn string
+
+	// Open issues mentioned by an open pull request move here from
+	// TodoColumn (or DoneColumn, if they've been reopened). Cards in any
+	// other column are assumed to be further along, and left alone.
+	InProgressColumn string
+}
+
+func listOrgProjects(ctx context.Context, client *gh.Client) []*gh.Project {
+	var allProjects []*gh.Project
+	opt := &gh.ProjectListOptions{
+		State:       "open",
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		projects, resp, err := client.Organizations.ListProjects(ctx, GITHUB_ORG_NAME, opt)
+		if err != nil {
+			fmt.Printf("Error fetching project list: %+v\n", err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		allProjects = append(allProjects, projects...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allProjects
+}
+
+// List the projects of a repo. Repos with projects disabled just have none.
+func listRepoProjects(ctx context.Context, client *gh.Client, repo string) []*gh.Project {
+	var allProjects []*gh.Project
+	opt := &gh.ProjectListOptions{
+		State:       "open",
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		projects, resp, err := client.Repositories.ListProjects(ctx, GITHUB_ORG_NAME, repo, opt)
+		if err != nil {
+			if resp != nil && (resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound) {
+				return nil
+			}
+			fmt.Printf("Error fetching project list for %s: %+v\n", repo, err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		allProjects = append(allProjects, projects...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allProjects
+}
+
+func listProjectColumns(ctx context.Context, client *gh.Client, projectID int64) []*gh.ProjectColumn {
+	var allColumns []*gh.ProjectColumn
+	opt := &gh.ListOptions{PerPage: 100}
+	for {
+		columns, resp, err := client.Projects.ListProjectColumns(ctx, projectID, opt)
+		if err != nil {
+			fmt.Printf("Error fetching project columns: %+v\n", err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		allColumns = append(allColumns, columns...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allColumns
+}
+
+func listColumnCards(ctx context.Context, client *gh.Client, columnID int64) []*gh.ProjectCard {
+	var allCards []*gh.ProjectCard
+	opt := &gh.ListOptions{PerPage: 100}
+	for {
+		cards, resp, err := client.Projects.ListProjectCards(ctx, columnID, opt)
+		if err != nil {
+			fmt.Printf("Error fetching column contents: %+v\n", err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		allCards = append(allCards, cards...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allCards
+}
+
+// Move cards around the boards named in rules, to match the state of
+// their issues. openIssues holds the repo#number strings of every open
+// issue and pull request we've seen; anything else gets looked up, as
+// it's either closed or in a repo we didn't scan. Cards for issues that
+// have been deleted are left alone. issuesWithOpenPRs holds the
+// repo#number strings of issues mentioned by open pull requests.
+func applyBoardRules(ctx context.Context, client *gh.Client, rules map[string]BoardRules, openIssues, issuesWithOpenPRs map[string]struct{}, knownIssues *issueCache, dryRun bool) {
+	for _, project := range listOrgProjects(ctx, client) {
+		pn := *(project.Name)
+		br, haveRules := rules[pn]
+		if !haveRules {
+			continue
+		}
+
+		fmt.Printf("### APPLYING BOARD RULES TO PROJECT: %s\n", pn)
+
+		columns := listProjectColumns(ctx, client, *(project.ID))
+		columnsByName := map[string]*gh.ProjectColumn{}
+		for _, column := range columns {
+			columnsByName[*(column.Name)] = column
+		}
+		missingColumn := false
+		for _, cn := range []string{br.DoneColumn, br.TodoColumn, br.InProgressColumn} {
+			_, found := columnsByName[cn]
+			if cn != "" && !found {
+				fmt.Printf("Project %s has no column called %q, so skipping it\n", pn, cn)
+				missingColumn = true
+			}
+		}
+		if missingColumn {
+			continue
+		}
+
+		for _, column := range columns {
+			cn := *(column.Name)
+			for _, card := range listColumnCards(ctx, client, *(column.ID)) {
+				if card.ContentURL == nil {
+					// Note cards have no issue to follow
+					continue
+				}
+
+				_, repo, number, err := utils.ParseContentURL(*(card.ContentURL))
+				if err != nil {
+					fmt.Printf("Error parsing card content: %s\n", err.Error())
+					os.Exit(1)
+				}
+				tag := fmt.Sprintf("%s#%d", repo, number)
+
+				target := ""
+				_, open := openIssues[tag]
+				if !open && cn != br.DoneColumn {
+					issue := knownIssues.get(ctx, client, tag)
+					if issue == nil {
+						fmt.Printf("The card for %s on %s is for an issue that doesn't exist any more\n", tag, pn)
+						continue
+					}
+
+					if issue.GetState() == "open" {
+						open = true
+					} else if issue.IsPullRequest() {
+						pull, resp, err := client.PullRequests.Get(ctx, GITHUB_ORG_NAME, repo, number)
+						if err != nil {
+							fmt.Printf("Error fetching pull request %s: %+v\n", tag, err)
+							os.Exit(1)
+						}
+						waitForRateLimit(resp)
+
+						if pull.GetMerged() {
+							target = br.DoneColumn
+						}
+					} else {
+						target = br.DoneColumn
+					}
+				}
+
+				if open {
+					_, hasOpenPR := issuesWithOpenPRs[tag]
+					if hasOpenPR && (cn == br.TodoColumn || cn == br.DoneColumn) {
+						target = br.InProgressColumn
+					}
+					if target == "" && cn == br.DoneColumn {
+						target = br.TodoColumn
+					}
+				}
+
+				if target == "" || target == cn {
+					continue
+				}
+
+				fmt.Printf("ACTION: Moving card for %s on %s from %s to %s\n", tag, pn, cn, target)
+				if !dryRun {
+					resp, err := client.Projects.MoveProjectCard(ctx, *(card.ID), &gh.ProjectCardMoveOptions{
+						Position: "top",
+						ColumnID: *(columnsByName[target].ID),
+					})
+					if err != nil {
+						fmt.Printf("Error moving card: %+v / %+v\n", err, resp)
+						os.Exit(1)
+					}
+					waitForRateLimit(resp)
+				}
+			}
+		}
+	}
+}
+
+// boardCard is a card for an issue or pull request, along with where it is
+type boardCard struct {
+	Project *gh.Project
+	Column  *gh.ProjectColumn
+	Card    *gh.ProjectCard
+}
+
+// Find issues with cards on more than one of the given projects, and
+// report them. If priority lists project names (highest priority first)
+// and one of an issue's boards clearly outranks the rest, the cards on
+// the other boards are removed.
+func fixCardsOnMultipleBoards(ctx context.Context, client *gh.Client, projects []*gh.Project, priority []string, dryRun bool) {
+	fmt.Printf("### LOOKING FOR ISSUES ON MORE THAN ONE BOARD\n")
+
+	rank := func(bc boardCard) int {
+		for idx, pn := range priority {
+			if pn == *(bc.Project.Name) {
+				return idx
+			}
+		}
+		return len(priority)
+	}
+
+	// repo#number strings -> every card for that issue
+	cardsByIssue := map[string][]boardCard{}
+
+	for _, project := range projects {
+		for _, column := range listProjectColumns(ctx, client, *(project.ID)) {
+			for _, card := range listColumnCards(ctx, client, *(column.ID)) {
+				if card.ContentURL == nil {
+					continue
+				}
+
+				_, repo, number, err := utils.ParseContentURL(*(card.ContentURL))
+				if err != nil {
+					fmt.Printf("Error parsing card content: %s\n", err.Error())
+					os.Exit(1)
+				}
+				tag := fmt.Sprintf("%s#%d", repo, number)
+
+				cardsByIssue[tag] = append(cardsByIssue[tag], boardCard{
+					Project: project,
+					Column:  column,
+					Card:    card,
+				})
+			}
+		}
+	}
+
+	tags := []string{}
+	for tag, cards := range cardsByIssue {
+		if len(cards) > 1 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		cards := cardsByIssue[tag]
+		sort.SliceStable(cards, func(i, j int) bool {
+			return rank(cards[i]) < rank(cards[j])
+		})
+
+		fmt.Printf("Issue %s is on %d boards:", tag, len(cards))
+		for _, bc := range cards {
+			fmt.Printf(" %s/%s", *(bc.Project.Name), *(bc.Column.Name))
+		}
+		fmt.Printf("\n")
+
+		if rank(cards[0]) == len(priority) || rank(cards[0]) == rank(cards[1]) {
+			fmt.Printf("Can't tell which board %s belongs on, leaving it alone\n", tag)
+			continue
+		}
+
+		for _, bc := range cards[1:] {
+			fmt.Printf("ACTION: Removing %s from %s, as it's on %s\n", tag, *(bc.Project.Name), *(cards[0].Project.Name))
+			if !dryRun {
+				resp, err := client.Projects.DeleteProjectCard(ctx, *(bc.Card.ID))
+				if err != nil {
+					fmt.Printf("Error removing card: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+		}
+	}
+}
+
+// The boards we've looked at through moveToColumn: the cards on each, by
+// project ID and then by the API URL of their issue, so we can find an
+// issue's card without looking at every board. Columns are by ID.
+var boardCardsByURL = map[int64]map[string]boardCard{}
+var columnsByID = map[int64]*gh.ProjectColumn{}
+
+// Find a column, and the cards on the board it's on
+func columnBoard(ctx context.Context, client *gh.Client, columnID int64) (*gh.ProjectColumn, map[string]boardCard) {
+	column, known := columnsByID[columnID]
+	if !known {
+		col, resp, err := client.Projects.GetProjectColumn(ctx, columnID)
+		if err != nil {
+			fmt.Printf("Error fetching column %d: %+v\n", columnID, err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+		column = col
+		columnsByID[columnID] = column
+	}
+
+	projectURL := column.GetProjectURL()
+	projectID, err := strconv.ParseInt(projectURL[strings.LastIndex(projectURL, "/")+1:], 10, 64)
+	if err != nil {
+		fmt.Printf("Error finding the project of column %d from %q: %s\n", columnID, projectURL, err.Error())
+		os.Exit(1)
+	}
+
+	cards, known := boardCardsByURL[projectID]
+	if !known {
+		cards = map[string]boardCard{}
+		for _, col := range listProjectColumns(ctx, client, projectID) {
+			columnsByID[col.GetID()] = col
+			for _, card := range listColumnCards(ctx, client, col.GetID()) {
+				if card.ContentURL != nil {
+					cards[*(card.ContentURL)] = boardCard{Column: col, Card: card}
+				}
+			}
+		}
+		boardCardsByURL[projectID] = cards
+	}
+
+	return column, cards
+}
+
+// Move an issue's card to a column, or give it one there if it isn't on
+// that column's board.
+func moveToColumn(ctx context.Context, client *gh.Client, tag string, issue *gh.Issue, columnID int64, dryRun bool) {
+	column, cards := columnBoard(ctx, client, columnID)
+
+	bc, onBoard := cards[issue.GetURL()]
+	switch {
+	case onBoard && bc.Column.GetID() == columnID:
+		fmt.Printf("Issue %s is already in %s\n", tag, column.GetName())
+		return
+
+	case onBoard:
+		fmt.Printf("ACTION: Moving %s from %s to %s\n", tag, bc.Column.GetName(), column.GetName())
+		if !dryRun {
+			resp, err := client.Projects.MoveProjectCard(ctx, bc.Card.GetID(), &gh.ProjectCardMoveOptions{
+				Position: "top",
+				ColumnID: columnID,
+			})
+			if err != nil {
+				fmt.Printf("Error moving card: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+
+	default:
+		fmt.Printf("ACTION: Putting %s into %s\n", tag, column.GetName())
+		if !dryRun {
+			card, resp, err := client.Projects.CreateProjectCard(ctx, columnID, &gh.ProjectCardOptions{
+				ContentType: "Issue",
+				ContentID:   issue.GetID(),
+			})
+			if err != nil {
+				fmt.Printf("Error creating card: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+			bc.Card = card
+		}
+	}
+
+	bc.Column = column
+	cards[issue.GetURL()] = bc
+}
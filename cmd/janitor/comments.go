@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// The template in COMMENT_TEMPLATES with the given name
+func commentTemplate(name string) string {
+	text, found := COMMENT_TEMPLATES[name]
+	if !found {
+		fmt.Printf("Error: there's no comment template called %s\n", name)
+		os.Exit(1)
+	}
+	return text
+}
+
+// The janitor's comment about key among an issue's comments, if it's
+// made one, and when it last commented on the issue about anything
+func findOwnComment(ctx context.Context, client *gh.Client, comments []*gh.IssueComment, key string) (*gh.IssueComment, time.Time) {
+	me := getJanitorLogin(ctx, client)
+
+	var own *gh.IssueComment
+	var last time.Time
+	for _, c := range comments {
+		if c.GetUser().GetLogin() != me {
+			continue
+		}
+		if c.GetUpdatedAt().After(last) {
+			last = c.GetUpdatedAt()
+		}
+		if utils.HasCommentMarker(c.GetBody(), key) {
+			own = c
+		}
+	}
+	return own, last
+}
+
+// Something the janitor has decided to say on an issue, which can be
+// posted once whatever it explains has been done
+type pendingComment struct {
+	repo   string
+	number int
+	key    string
+	body   string
+
+	// The janitor's earlier comment about key, if there is one
+	previous *gh.IssueComment
+
+	// Post the comment afresh even if it says the same as last time
+	renew bool
+}
+
+// Work out what to say on an issue, using a comment template (see
+// text/template) filled in with data. The janitor only keeps one
+// comment about each key on an issue: if it's said something about key
+// before, that comment is updated rather than a new one being made. To
+// keep the noise down, it won't comment on an issue at all if it last
+// did less than COMMENT_COOLDOWN ago, in which case this returns nil;
+// callers should hold off doing whatever the comment would explain
+// until it can be said. With renew, the comment is posted afresh even if
+// it hasn't changed, so its timestamp shows when it was last said.
+func prepareComment(ctx context.Context, client *gh.Client, repo string, number int, key, text string, data interface{}, renew bool) *pendingComment {
+	tag := fmt.Sprintf("%s#%d", repo, number)
+
+	body, err := utils.RenderComment(key, text, data)
+	if err != nil {
+		fmt.Printf("Error in comment template for %s: %s\n", key, err.Error())
+		os.Exit(1)
+	}
+
+	previous, last := findOwnComment(ctx, client, listIssueComments(ctx, client, repo, number), key)
+	unchanged := previous != nil && previous.GetBody() == body && !renew
+	if since := time.Since(last); since < COMMENT_COOLDOWN && !unchanged {
+		fmt.Printf("Not commenting on %s about %s, as I last commented on it %s ago\n", tag, key, since.Round(time.Minute).String())
+		return nil
+	}
+
+	return &pendingComment{
+		repo:     repo,
+		number:   number,
+		key:      key,
+		body:     body,
+		previous: previous,
+		renew:    renew,
+	}
+}
+
+// Post a comment, or update the janitor's earlier one. Returns whether
+// anything changed.
+func (pc *pendingComment) post(ctx context.Context, client *gh.Client, dryRun bool) bool {
+	tag := fmt.Sprintf("%s#%d", pc.repo, pc.number)
+
+	if pc.previous != nil && pc.previous.GetBody() == pc.body && !pc.renew {
+		return false
+	}
+
+	if pc.previous != nil && pc.previous.GetBody() == pc.body {
+		// Editing a comment without changing it doesn't update its
+		// timestamp, so replace it instead
+		fmt.Printf("ACTION: Replacing my comment on %s about %s\n", tag, pc.key)
+		if !dryRun {
+			resp, err := client.Issues.DeleteComment(ctx, GITHUB_ORG_NAME, pc.repo, int(pc.previous.GetID()))
+			if err != nil {
+				fmt.Printf("Error deleting comment: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+	} else if pc.previous != nil {
+		fmt.Printf("ACTION: Updating my comment on %s about %s: %s\n", tag, pc.key, pc.body)
+		if !dryRun {
+			_, resp, err := client.Issues.EditComment(ctx, GITHUB_ORG_NAME, pc.repo, int(pc.previous.GetID()), &gh.IssueComment{
+				Body: &pc.body,
+			})
+			if err != nil {
+				fmt.Printf("Error updating comment: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+		return true
+	}
+
+	fmt.Printf("ACTION: Commenting on %s about %s: %s\n", tag, pc.key, pc.body)
+	if !dryRun {
+		_, resp, err := client.Issues.CreateComment(ctx, GITHUB_ORG_NAME, pc.repo, pc.number, &gh.IssueComment{
+			Body: &pc.body,
+		})
+		if err != nil {
+			fmt.Printf("Error commenting on issue: %+v / %+v\n", err, resp)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+	}
+	return true
+}
+
+// Say something on an issue straight away, if the cooldown allows (see
+// prepareComment). Returns whether it commented.
+func postComment(ctx context.Context, client *gh.Client, repo string, number int, key, text string, data interface{}, dryRun bool) bool {
+	pc := prepareComment(ctx, client, repo, number, key, text, data, false)
+	if pc == nil {
+		return false
+	}
+	return pc.post(ctx, client, dryRun)
+}
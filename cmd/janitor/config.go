@@ -0,0 +1,295 @@
+package main
+
+import (
+	"time"
+)
+
+// Ignore these repos
+var GITHUB_IGNORED_REPOS = map[string]struct{}{
+	"roadmap":             struct{}{},
+	"moby-counter-issues": struct{}{},
+}
+
+// Ignore issues with these labels
+var GITHUB_IGNORED_LABELS = map[string]struct{}{
+	"hypothesis": struct{}{},
+	"bot":        struct{}{},
+}
+
+// Labels that should be renamed. Renames happen *before*
+// DESIRED_LABELS / UNDESIRED_LABELS are considered.
+var RENAME_THESE_LABELS = map[string]string{
+	/* Proposed rename to make it clear you pick exactly one of these (and to make them sort consistently against importance:* and urgency:*
+
+	If we adopt this, update the corresponding names in DESIRED_LABELS.
+
+	"task":  "type:task",
+	"bug":   "type:bug",
+	"debt":  "type:debt",
+	"epic":  "type:epic",
+	"theme": "type:theme",
+	*/
+}
+
+// Labels we want in every repo, with their colours
+var DESIRED_LABELS = map[string]string{
+	// Issue types
+	"task":  "84b6eb",
+	"bug":   "f03838",
+	"debt":  "dbba69",
+	"epic":  "7744aa",
+	"theme": "7744aa",
+	"bot":   "EEF5DB",
+
+	// Flag to mark an issue as coming from the support team, so they
+	// can find them and update users
+	"support": "77f252",
+
+	// Urgency: How soon do we need it?
+	"urgency:high":   "c40000",
+	"urgency:medium": "ffff00",
+	"urgency:low":    "00ba00",
+
+	// Importance: How much do we need it?
+	"importance:high":   "ffaaaa",
+	"importance:medium": "ffffaa",
+	"importance:low":    "aaffaa",
+
+	// Flag for issues that have sat in triage for too long
+	"triage-overdue": "d93f0b",
+
+	// Flag for epics whose issues are all closed
+	"ready-to-close": "0e8a16",
+
+	// Flag for issues nobody has touched in a while
+	"stale": "ededed",
+}
+
+// Labels we want to delete if found
+var UNDESIRED_LABELS = map[string]struct{}{
+	"P0":                 struct{}{},
+	"P1":                 struct{}{},
+	"P2":                 struct{}{},
+	"P3":                 struct{}{},
+	"code-review":        struct{}{},
+	"ready-for-sign-off": struct{}{},
+	"duplicate":          struct{}{},
+	"enhancement":        struct{}{},
+	"good first issue":   struct{}{},
+	"help wanted":        struct{}{},
+	"invalid":            struct{}{},
+	"question":           struct{}{},
+	"wontfix":            struct{}{},
+}
+
+// Labels to add to open issues automatically. Each rule's label must be
+// in DESIRED_LABELS.
+var LABEL_RULES = []LabelRule{
+	/* For example:
+
+	{
+		Label:        "bug",
+		TitleMatches: `(?i)\bpanic:`,
+	},
+	{
+		Label:      "support",
+		AuthorTeam: "support",
+	},
+	*/
+}
+
+// Milestones we want in every repo, by title
+var DESIRED_MILESTONES = map[string]DesiredMilestone{
+	/* For example:
+
+	"0.9": {
+		Description: "The release after 0.8",
+		DueOn:       time.Date(2018, 9, 30, 0, 0, 0, 0, time.UTC),
+	},
+	*/
+}
+
+// Close open milestones that are past their due date and have no
+// issues in them
+var CLOSE_PAST_DUE_MILESTONES = false
+
+// Labels that make an issue count as an epic, and what kind of epic.
+// Themes contain epics, which contain tasks.
+var EPIC_LABELS = map[string]string{
+	"epic":  "epic",
+	"theme": "theme",
+}
+
+// Kinds of epic that should be in another kind of epic. We report the
+// ones that aren't.
+var EPIC_PARENT_KINDS = map[string]string{
+	"epic": "theme",
+}
+
+// Rules for moving cards between columns on classic project
+// boards, keyed by project name
+var BOARD_RULES = map[string]BoardRules{
+	/* For example:
+
+	"Engineering": {
+		DoneColumn:       "Done",
+		TodoColumn:       "To do",
+		InProgressColumn: "In progress",
+	},
+	*/
+}
+
+// What to do about issues that sit in the triage column for too long.
+// Out of the box they're only listed; set Label (and MentionTeam) to
+// label them and ping a team about them as well.
+var TRIAGE_ESCALATION = TriageEscalation{
+	MaxAge: 14 * 24 * time.Hour,
+	Digest: true,
+
+	// For example:
+	// Label:       "triage-overdue",
+	// MentionTeam: "dotmesh-io/engineering",
+}
+
+// Comment on issues as they're put into triage, saying why (using the
+// "triaged" comment template). Cards for issues can't carry notes, so
+// a comment is the only place the explanation can go.
+var EXPLAIN_TRIAGE = false
+
+// Project boards in priority order, highest first. When an issue
+// has cards on more than one board, the card on the highest priority
+// board is kept and the others are removed; boards not listed here
+// rank below all the ones that are. Leave empty to just report such
+// issues, as removed cards can't be got back.
+var PROJECT_PRIORITY = []string{
+	// For example: "Engineering",
+}
+
+// Count issues linked to an epic as sub-issues (or tracked issues)
+// in the Github UI as being in the epic, as well as the ones
+// mentioned in its body
+var EPIC_LINKED_ISSUES = true
+
+// Count issues mentioned in comments on an epic as being in the
+// epic, as well as the ones mentioned in its body. This costs an
+// extra API call or more per epic.
+var EPIC_COMMENTS = true
+
+// Report references in epics to issues that don't exist or are closed,
+// pull requests, and issues in archived or ignored repos or other orgs
+var LINT_EPICS = true
+
+// Report issues that say they're blocked by (or depend on) issues that
+// are now closed, issues that say they're duplicates but are still
+// open, and issues that are waiting on each other
+var REPORT_DEPENDENCIES = true
+
+// Tick the boxes in epics' task lists for closed issues, and untick
+// them for open ones. This edits the body of every epic that's out of
+// date.
+var SYNC_EPIC_CHECKBOXES = false
+
+// Issues with this label count as bugs when summarising epics
+var BUG_LABEL = "bug"
+
+// Keep a summary of each epic's progress in its body, between marker
+// comments, as well as printing it out. This edits the body of every
+// epic.
+var EPIC_PROGRESS_BLOCK = false
+
+// What to do with epics once every issue in them is closed, by repo.
+// The "" entry covers repos that aren't listed. Out of the box they're
+// only reported; use LABEL_FINISHED_EPIC or CLOSE_FINISHED_EPIC to do
+// something about them.
+var FINISHED_EPICS = map[string]FinishedEpicAction{
+	"": REPORT_FINISHED_EPIC,
+}
+
+// The label LABEL_FINISHED_EPIC adds
+var READY_TO_CLOSE_LABEL = "ready-to-close"
+
+// When issues go stale, and what happens to them then, by repo. The ""
+// entry covers repos that aren't listed. Nothing is marked or closed
+// unless there's an entry for the repo or a "" entry; leave CloseAfter
+// out to only mark stale issues.
+var STALE_ISSUES = map[string]StaleIssues{
+	/* For example:
+
+	"": {
+		MarkAfter:    90 * 24 * time.Hour,
+		CloseAfter:   14 * 24 * time.Hour,
+		Label:        "stale",
+		ExemptLabels: []string{"epic", "theme", "urgency:high"},
+	},
+	*/
+}
+
+// When pull requests go stale, and what happens to them then, by repo.
+// The "" entry covers repos that aren't listed. Nothing is marked or
+// closed unless there's an entry for the repo or a "" entry; leave
+// CloseDraftsAfter out to only mark stale pull requests.
+var STALE_PULL_REQUESTS = map[string]StalePullRequests{
+	/* For example:
+
+	"": {
+		MarkAfter:        30 * 24 * time.Hour,
+		Label:            "stale",
+		CloseDraftsAfter: 30 * 24 * time.Hour,
+	},
+	*/
+}
+
+// How to spot and deal with open issues that look like duplicates
+var DUPLICATE_DETECTION = DuplicateDetection{
+	Threshold: 0.6,
+	Comment:   false,
+}
+
+// What to check about who issues are assigned to
+var ASSIGNEE_RULES = AssigneeRules{
+	UrgentLabel:      "urgency:high",
+	MaxAssigned:      15,
+	CheckDeparted:    true,
+	UnassignDeparted: false,
+}
+
+// How long a script can spend deciding what to do with one issue before
+// the janitor gives up on it, and stops the run. The vendored Starlark
+// can't stop a script that's already running, so one that times out is
+// left running in the background until the janitor exits; ending the
+// run there stops a stuck script from holding up the rest, or piling up
+// more copies of itself for every other issue.
+var SCRIPT_TIMEOUT = 5 * time.Second
+
+// What the janitor says when it comments, as Go templates (see
+// text/template). What each one can use is listed above it.
+var COMMENT_TEMPLATES = map[string]string{
+	// .Team, .Days
+	"triage-overdue": "@{{.Team}} this issue has been waiting in triage for {{.Days}} days - please give it a home on a project board or in an epic.",
+
+	// .Days, .Label, .CloseInDays (0 if it won't be closed)
+	"stale-issue": "This issue hasn't seen any activity for {{.Days}} days, so I've marked it as {{.Label}}." +
+		"{{if .CloseInDays}} It'll be closed in {{.CloseInDays}} days unless somebody comments on it.{{else}} Comment on it if it's still relevant.{{end}}",
+
+	// .Mentions, .Days, .Label, .CloseInDays (0 if it won't be closed)
+	"stale-pull-request": "{{.Mentions}} this pull request hasn't had any commits or reviews for {{.Days}} days, so I've marked it as {{.Label}}." +
+		"{{if .CloseInDays}} As it's a draft, it'll be closed in {{.CloseInDays}} days unless there's some activity on it.{{else}} Is it still needed?{{end}}",
+
+	// .Original, .Percent
+	"possible-duplicate": "This looks like it might be a duplicate of {{.Original}}. If it is, please close one of them!",
+
+	// .Labels, each with .Label and .Why
+	"auto-labelled": "{{range .Labels}}I've labelled this as {{.Label}} because {{.Why}}.\n{{end}}",
+
+	// .Epics (how many epics and themes were checked)
+	"triaged": "I've put this issue into triage, as it's not on any project board and not referenced by any epic or theme (checked {{.Epics}} epics).",
+
+	// .Total, .Kind
+	"finished-epic": "All {{.Total}} issues in this {{.Kind}} are closed, so I'm closing it too. Reopen it if there's more to do!",
+}
+
+// The janitor won't comment on an issue more often than this
+var COMMENT_COOLDOWN = 24 * time.Hour
+
+// Set to true to prevent any actual changes happening on Github
+var DRY_RUN = false
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// Go through what open issues and pull requests say they're blocked by
+// or depend on, and report the ones that are waiting on issues that are
+// closed (or gone), the ones that say they're duplicates of other
+// issues but are still open, and any loops of issues waiting on each
+// other. openIssues are the repo#number strings of all the open issues
+// in the org, which knownIssues already has.
+func reportDependencies(ctx context.Context, client *gh.Client, openIssues map[string]struct{}, knownIssues *issueCache) {
+	tags := []string{}
+	for tag, _ := range openIssues {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	// Who's waiting on whom
+	blockers := map[string][]string{}
+
+	for _, tag := range tags {
+		issue := knownIssues.issues[tag]
+		repo, _, err := utils.ParseIssueTag(tag)
+		if err != nil {
+			fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+			continue
+		}
+
+		// An issue can mention the same one more than once ("blocked by
+		// #1 ... depends on #1"), but it only counts once
+		seen := map[string]struct{}{}
+		for _, rel := range utils.ParseIssueRelations(issue.GetBody(), GITHUB_ORG_NAME, repo) {
+			if !strings.EqualFold(rel.Ref.Owner, GITHUB_ORG_NAME) {
+				continue
+			}
+			other := repoNames.Tag(rel.Ref.Tag())
+			if other == tag {
+				continue
+			}
+			key := "duplicate of " + other
+			if rel.Kind.Blocks() {
+				key = "blocked by " + other
+			}
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			switch {
+			case rel.Kind.Blocks():
+				blockers[tag] = append(blockers[tag], other)
+			case rel.Kind == utils.RelDuplicateOf && !issue.IsPullRequest():
+				fmt.Printf("Issue %s says it's a duplicate of %s, but is still open\n", tag, other)
+			}
+		}
+	}
+
+	for _, tag := range tags {
+		if len(blockers[tag]) == 0 {
+			continue
+		}
+
+		finished := []string{}
+		state := "closed"
+		for _, other := range blockers[tag] {
+			if _, open := openIssues[other]; open {
+				continue
+			}
+			blocker := knownIssues.get(ctx, client, other)
+			if blocker == nil {
+				finished = append(finished, other)
+				state = "closed or deleted"
+			} else if blocker.GetState() == "closed" {
+				finished = append(finished, other)
+			}
+		}
+
+		verb := "are"
+		if len(finished) == 1 {
+			verb = "is"
+		}
+		if len(finished) == len(blockers[tag]) {
+			if len(finished) > 1 {
+				verb = "are all"
+			}
+			fmt.Printf("Issue %s is no longer blocked: %s %s %s\n", tag, strings.Join(finished, ", "), verb, state)
+		} else if len(finished) > 0 {
+			fmt.Printf("Issue %s is still blocked, but %s %s %s\n", tag, strings.Join(finished, ", "), verb, state)
+		}
+	}
+
+	for _, cycle := range utils.FindCycles(blockers) {
+		fmt.Printf("Issues are waiting on each other: %s -> %s\n", strings.Join(cycle, " -> "), cycle[0])
+	}
+}
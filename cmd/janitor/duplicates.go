@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// DuplicateDetection says how alike two issues' titles and bodies have
+// to be to count as possible duplicates, and what to do about them.
+type DuplicateDetection struct {
+	// From 0 to 1; zero disables the lot. Around 0.5 catches issues
+	// that are obviously about the same thing.
+	Threshold float64
+
+	// Comment "possible duplicate of X" on the newer issue of each
+	// similar pair, updating the comment if X changes
+	Comment bool
+}
+
+// Compare every open issue with every other, and report groups of
+// issues that look like they're about the same thing. The comparison
+// happens here rather than on Github, so it needs no API calls apart
+// from the comments. openIssues are the repo#number strings of all the
+// open issues in the org, which knownIssues already has.
+func reportDuplicates(ctx context.Context, client *gh.Client, config DuplicateDetection, openIssues map[string]struct{}, knownIssues *issueCache, dryRun bool) {
+	if config.Threshold == 0 {
+		return
+	}
+
+	tags := []string{}
+	for tag, _ := range openIssues {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	index := utils.NewSimilarityIndex()
+	for _, tag := range tags {
+		issue := knownIssues.issues[tag]
+		if issue.IsPullRequest() {
+			continue
+		}
+		body := utils.StripManagedBlock(issue.GetBody(), utils.ProgressBlockStart, utils.ProgressBlockEnd)
+
+		// Titles say the most about what an issue is, so count them twice
+		index.Add(tag, issue.GetTitle(), issue.GetTitle(), body)
+	}
+
+	pairs := index.SimilarPairs(config.Threshold)
+	if len(pairs) == 0 {
+		return
+	}
+
+	fmt.Printf("### POSSIBLE DUPLICATES\n")
+	for _, p := range pairs {
+		fmt.Printf("Issue %s is %d%% like %s\n", p.A, p.Percent(), p.B)
+	}
+	for _, cluster := range utils.SimilarClusters(pairs) {
+		titles := []string{}
+		for _, tag := range cluster {
+			titles = append(titles, fmt.Sprintf("%s (%s)", tag, knownIssues.issues[tag].GetTitle()))
+		}
+		fmt.Printf("Issues that look alike: %s\n", strings.Join(titles, ", "))
+	}
+
+	if !config.Comment {
+		return
+	}
+
+	// Point each newer issue at the older one it's most like. Pairs are
+	// most similar first, so the first one we see for each issue wins.
+	commented := map[string]struct{}{}
+	for _, p := range pairs {
+		older, newer := p.A, p.B
+		if knownIssues.issues[newer].GetCreatedAt().Before(knownIssues.issues[older].GetCreatedAt()) {
+			older, newer = newer, older
+		}
+		if _, done := commented[newer]; done {
+			continue
+		}
+		commented[newer] = struct{}{}
+
+		repo, number, err := utils.ParseIssueTag(newer)
+		if err != nil {
+			fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		postComment(ctx, client, repo, number, "duplicate", commentTemplate("possible-duplicate"), struct {
+			Original string
+			Percent  int
+		}{GITHUB_ORG_NAME + "/" + older, p.Percent()}, dryRun)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// epicIndex records which issues are in an epic. Issues can be in an
+// epic by being mentioned in its body, or by being linked to it as a
+// sub-issue or tracked issue through the Github UI.
+type epicIndex struct {
+	// repo#number strings of epics -> the epics themselves, and what
+	// kind of epic (from EPIC_LABELS) they are
+	epics map[string]*gh.Issue
+	kinds map[string]string
+
+	// repo#number strings of epics -> the issues in them, in the order
+	// we found them
+	members map[string][]string
+
+	// repo#number strings of issues in epics -> the epic they're in
+	byTag map[string]string
+
+	// node IDs of issues in epics -> the repo#number the epic knows
+	// them by
+	byNodeID map[string]string
+
+	// Set once Github has refused to tell us about sub-issues or
+	// tracked issues, so we don't keep asking
+	noSubIssues     bool
+	noTrackedIssues bool
+}
+
+func newEpicIndex() *epicIndex {
+	return &epicIndex{
+		epics:    map[string]*gh.Issue{},
+		kinds:    map[string]string{},
+		members:  map[string][]string{},
+		byTag:    map[string]string{},
+		byNodeID: map[string]string{},
+	}
+}
+
+// Record an epic, along with every issue it mentions in its body (or
+// comments, if EPIC_COMMENTS is set) or has linked to it (if
+// EPIC_LINKED_ISSUES is set).
+func (ei *epicIndex) addEpic(ctx context.Context, client *gh.Client, repo string, issue gh.Issue, kind string) {
+	issueTag := fmt.Sprintf("%s#%d", repo, *(issue.Number))
+	ei.epics[issueTag] = &issue
+	ei.kinds[issueTag] = kind
+
+	// Don't count what we wrote in the progress block as mentions
+	body := utils.StripManagedBlock(issue.GetBody(), utils.ProgressBlockStart, utils.ProgressBlockEnd)
+
+	mentionedIssues := utils.ParseBodyForIssueLinks(body, GITHUB_ORG_NAME, repo)
+	fmt.Printf("Issue %s is an epic, mentioning these issues: %v!\n", issueTag, mentionedIssues)
+
+	if EPIC_COMMENTS && issue.GetComments() > 0 {
+		me := getJanitorLogin(ctx, client)
+		for _, comment := range listIssueComments(ctx, client, repo, *(issue.Number)) {
+			// What the janitor says about an epic (such as that it
+			// looks like a duplicate) doesn't put issues in it
+			if comment.GetUser().GetLogin() == me {
+				continue
+			}
+			mentionedInComment := utils.ParseBodyForIssueLinks(comment.GetBody(), GITHUB_ORG_NAME, repo)
+			if len(mentionedInComment) > 0 {
+				fmt.Printf("Comment on epic %s mentions these issues: %v\n", issueTag, mentionedInComment)
+				mentionedIssues = append(mentionedIssues, mentionedInComment...)
+			}
+		}
+	}
+	for _, mi := range mentionedIssues {
+		ei.addMention(issueTag, mi)
+	}
+
+	if EPIC_LINKED_ISSUES {
+		linkedIssues := ei.addLinkedIssues(ctx, client, issueTag, issue.GetNodeID())
+		if len(linkedIssues) > 0 {
+			fmt.Printf("Issue %s has these linked issues: %v\n", issueTag, linkedIssues)
+		}
+	}
+}
+
+// Record that an epic mentions an issue
+func (ei *epicIndex) addMention(epic, tag string) {
+	tag = repoNames.Tag(tag)
+	ei.byTag[tag] = epic
+	for _, m := range ei.members[epic] {
+		if m == tag {
+			return
+		}
+	}
+	ei.members[epic] = append(ei.members[epic], tag)
+}
+
+// Build the hierarchy of epics, and the issues in them
+func (ei *epicIndex) hierarchy() *utils.Hierarchy {
+	h := utils.NewHierarchy()
+	for tag, epic := range ei.epics {
+		h.AddContainer(tag, ei.kinds[tag], epic.GetTitle())
+	}
+	for epic, members := range ei.members {
+		for _, m := range members {
+			h.AddChild(epic, m)
+		}
+	}
+	return h
+}
+
+// Report loops of epics that contain each other, and epics that
+// aren't in the kind of epic EPIC_PARENT_KINDS says they should be
+func reportHierarchyProblems(h *utils.Hierarchy) {
+	for _, cycle := range h.Cycles() {
+		fmt.Printf("Epics contain each other in a loop: %v\n", cycle)
+	}
+	for kind, parentKind := range EPIC_PARENT_KINDS {
+		for _, tag := range h.Orphans(kind, parentKind) {
+			fmt.Printf("The %s %s isn't in a %s\n", kind, tag, parentKind)
+		}
+	}
+}
+
+// Find out if an issue is in an epic, returning the repo#number the
+// epic knows it by and the epic it's in
+func (ei *epicIndex) lookup(tag, nodeID string) (string, string, bool) {
+	epic, found := ei.byTag[tag]
+	if found {
+		return tag, epic, true
+	}
+	knownAs, found := ei.byNodeID[nodeID]
+	if found && nodeID != "" {
+		return knownAs, ei.byTag[knownAs], true
+	}
+	return "", "", false
+}
+
+// Look up the node IDs of every issue mentioned in an epic, so we can
+// recognise them even if they've been transferred since. This means
+// asking Github about every one we haven't already seen, so it's only
+// worth doing if we have to.
+func (ei *epicIndex) resolveMentions(ctx context.Context, client *gh.Client, ids *issueCache) {
+	for tag, _ := range ei.byTag {
+		nodeID := ids.resolve(ctx, client, tag)
+		if nodeID != "" {
+			if _, known := ei.byNodeID[nodeID]; !known {
+				ei.byNodeID[nodeID] = tag
+			}
+		}
+	}
+}
+
+// Issues linked to another one through the Github UI, as returned by
+// GraphQL
+type linkedIssuesResult struct {
+	Node struct {
+		SubIssues     *linkedIssueNodes `json:"subIssues"`
+		TrackedIssues *linkedIssueNodes `json:"trackedIssues"`
+	} `json:"node"`
+}
+
+type linkedIssueNodes struct {
+	Nodes []struct {
+		ID         string `json:"id"`
+		Number     int    `json:"number"`
+		Repository struct {
+			Name  string `json:"name"`
+			Owner struct {
+				Login string `json:"login"`
+			} `json:"owner"`
+		} `json:"repository"`
+	} `json:"nodes"`
+}
+
+// Github allows at most 100 sub-issues per issue, so we don't need to
+// page through them
+const linkedIssueFields = `nodes { id number repository { name owner { login } } }`
+
+const subIssuesQuery = `query($id: ID!) {
+  node(id: $id) {
+    ... on Issue { subIssues(first: 100) { ` + linkedIssueFields + ` } }
+  }
+}`
+
+const trackedIssuesQuery = `query($id: ID!) {
+  node(id: $id) {
+    ... on Issue { trackedIssues(first: 100) { ` + linkedIssueFields + ` } }
+  }
+}`
+
+// Add the sub-issues and tracked issues of an epic to the index, as
+// they're in the epic even if its body doesn't mention them. Returns
+// the repo#number strings of the issues found.
+func (ei *epicIndex) addLinkedIssues(ctx context.Context, client *gh.Client, epic, epicNodeID string) []string {
+	vars := map[string]interface{}{"id": epicNodeID}
+
+	results := []*linkedIssueNodes{}
+
+	if !ei.noSubIssues {
+		var subIssues linkedIssuesResult
+		err := graphQL(ctx, client, subIssuesQuery, vars, &subIssues)
+		if err != nil {
+			fmt.Printf("Can't fetch sub-issues of %s, not counting them from now on: %s\n", epic, err.Error())
+			ei.noSubIssues = true
+		} else {
+			results = append(results, subIssues.Node.SubIssues)
+		}
+	}
+
+	if !ei.noTrackedIssues {
+		var trackedIssues linkedIssuesResult
+		err := graphQL(ctx, client, trackedIssuesQuery, vars, &trackedIssues)
+		if err != nil {
+			// Tracked issues came and went with Github's task lists
+			// beta, so we carry on without them
+			fmt.Printf("Can't fetch tracked issues, only counting sub-issues from now on: %s\n", err.Error())
+			ei.noTrackedIssues = true
+		} else {
+			results = append(results, trackedIssues.Node.TrackedIssues)
+		}
+	}
+
+	found := []string{}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, n := range result.Nodes {
+			if n.Repository.Owner.Login != GITHUB_ORG_NAME {
+				continue
+			}
+			tag := fmt.Sprintf("%s#%d", n.Repository.Name, n.Number)
+			ei.addMention(epic, tag)
+			ei.byNodeID[n.ID] = tag
+			found = append(found, tag)
+		}
+	}
+	return found
+}
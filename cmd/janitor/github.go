@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+func newGithubClient(ctx context.Context) *gh.Client {
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: os.Getenv("GITHUB_AUTH_TOKEN")},
+	)
+	tc := oauth2.NewClient(ctx, ts)
+	return gh.NewClient(tc)
+}
+
+// The login of the account the janitor runs as, so we can tell its own
+// comments from other people's
+var janitorLogin string
+
+func getJanitorLogin(ctx context.Context, client *gh.Client) string {
+	if janitorLogin != "" {
+		return janitorLogin
+	}
+	user, resp, err := client.Users.Get(ctx, "")
+	if err != nil {
+		fmt.Printf("Error finding out who we are: %+v\n", err)
+		os.Exit(1)
+	}
+	waitForRateLimit(resp)
+	janitorLogin = user.GetLogin()
+	return janitorLogin
+}
+
+// Pause if we're about to run out of API calls
+func waitForRateLimit(resp *gh.Response) {
+	if resp.Rate.Remaining <= 5 {
+		delay := time.Until(resp.Rate.Reset.Time)
+		fmt.Printf("[rl] %s\n", delay.String())
+		time.Sleep(delay)
+	}
+}
+
+// The logins of everyone in the org
+func listOrgMembers(ctx context.Context, client *gh.Client) map[string]struct{} {
+	members := map[string]struct{}{}
+	opt := &gh.ListMembersOptions{
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		users, resp, err := client.Organizations.ListMembers(ctx, GITHUB_ORG_NAME, opt)
+		if err != nil {
+			fmt.Printf("Error fetching org members: %+v\n", err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		for _, u := range users {
+			members[u.GetLogin()] = struct{}{}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return members
+}
+
+// The real names of every repo listOrgRepos has found, so references
+// to issues in them can be matched up whatever case they're in
+var repoNames = utils.RepoNames{}
+
+func listOrgRepos(ctx context.Context, client *gh.Client) []*gh.Repository {
+	opt := &gh.RepositoryListByOrgOptions{
+		Type:        "all",
+		ListOptions: gh.ListOptions{PerPage: 500},
+	}
+
+	var allRepos []*gh.Repository
+
+	for {
+		repos, resp, err := client.Repositories.ListByOrg(ctx, GITHUB_ORG_NAME, opt)
+		if err != nil {
+			fmt.Printf("Error fetching repositories: %+v\n", err)
+			os.Exit(1)
+		}
+
+		for _, repo := range repos {
+			repoNames.Add(repo.GetName())
+		}
+		allRepos = append(allRepos, repos...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allRepos
+}
+
+// go-github only speaks the REST API, so GraphQL queries are posted by
+// hand. The "data" part of the response is decoded into result.
+func graphQL(ctx context.Context, client *gh.Client, query string, variables map[string]interface{}, result interface{}) error {
+	req, err := client.NewRequest("POST", "graphql", map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+	// Sub-issues are still a preview feature
+	req.Header.Set("GraphQL-Features", "sub_issues")
+
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	resp, err := client.Do(ctx, req, &response)
+	if err != nil {
+		return err
+	}
+	waitForRateLimit(resp)
+
+	if len(response.Errors) > 0 {
+		messages := []string{}
+		for _, e := range response.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("GraphQL query failed: %s", strings.Join(messages, "; "))
+	}
+
+	return json.Unmarshal(response.Data, result)
+}
+
+func listIssueComments(ctx context.Context, client *gh.Client, repo string, number int) []*gh.IssueComment {
+	var allComments []*gh.IssueComment
+	opt := &gh.IssueListCommentsOptions{
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		comments, resp, err := client.Issues.ListComments(ctx, GITHUB_ORG_NAME, repo, number, opt)
+		if err != nil {
+			fmt.Printf("Error fetching comments on %s#%d: %+v\n", repo, number, err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		allComments = append(allComments, comments...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allComments
+}
+
+func searchIssues(ctx context.Context, client *gh.Client, query string) []gh.Issue {
+	var allIssues []gh.Issue
+	opt := &gh.SearchOptions{
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, resp, err := client.Search.Issues(ctx, query, opt)
+		if err != nil {
+			fmt.Printf("Error fetching issue list: %+v\n", err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		allIssues = append(allIssues, issues.Issues...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allIssues
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// issueCache holds the issues we know about, by their repo#number
+// strings, so we only ask Github about each one once. Unlike its repo
+// and number, an issue's node ID stays the same when it's transferred
+// to another repo, so it's what we compare when we need to know if two
+// references are to the same issue.
+type issueCache struct {
+	issues map[string]*gh.Issue
+
+	// repo#number strings that don't exist (any more)
+	missing map[string]struct{}
+}
+
+func newIssueCache() *issueCache {
+	return &issueCache{
+		issues:  map[string]*gh.Issue{},
+		missing: map[string]struct{}{},
+	}
+}
+
+// Record an issue we've found some other way, such as a search
+func (ic *issueCache) add(tag string, issue gh.Issue) {
+	ic.issues[tag] = &issue
+}
+
+// The node ID of an issue we've already seen, or "" if we haven't
+func (ic *issueCache) knownNodeID(tag string) string {
+	issue, known := ic.issues[tag]
+	if !known {
+		return ""
+	}
+	return issue.GetNodeID()
+}
+
+// Find an issue from its repo#number string, asking Github if we
+// haven't seen it before. Github redirects requests for transferred
+// issues to wherever they are now, so this finds the issue under its
+// old name too. Returns nil for issues that don't exist (any more).
+func (ic *issueCache) get(ctx context.Context, client *gh.Client, tag string) *gh.Issue {
+	issue, known := ic.issues[tag]
+	if known {
+		return issue
+	}
+	if _, missing := ic.missing[tag]; missing {
+		return nil
+	}
+
+	repo, number, err := utils.ParseIssueTag(tag)
+	if err != nil {
+		fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	issue, resp, err := client.Issues.Get(ctx, GITHUB_ORG_NAME, repo, number)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone) {
+			fmt.Printf("Issue %s doesn't exist\n", tag)
+			ic.missing[tag] = struct{}{}
+			return nil
+		}
+		fmt.Printf("Error fetching issue %s: %+v\n", tag, err)
+		os.Exit(1)
+	}
+	waitForRateLimit(resp)
+
+	ic.issues[tag] = issue
+	return issue
+}
+
+// Find the node ID of an issue from its repo#number string, or "" if
+// it doesn't exist
+func (ic *issueCache) resolve(ctx context.Context, client *gh.Client, tag string) string {
+	return ic.get(ctx, client, tag).GetNodeID()
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"reflect"
 	"testing"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
 )
 
-func testParseBodyForIssueLinks(t *testing.T) {
+func TestParseBodyForIssueLinks(t *testing.T) {
 
 	sampleBody := `As discussed in the Engineering Banner meeting, we want to tame the board: https://docs.google.com/document/d/1XUJRJ1upx3vn0jyu1JNQL-6lfgp4YMC_fZZ_tPJfVHA/edit#bookmark=id.ggjx2k8m2k4x
 
@@ -15,6 +18,14 @@ Here's the actions we made issues of to make this happen:
 - [ ] https://github.com/dotmesh-io/github-issue-janitor/issues/7 - automate cleanup of done issues from project boards
 - [ ] #346 - with that groundwork in place, we tidy up the Engineering board.`
 
-	il := parseBodyForIssueLinks(sampleBody, "testrepo")
-	t.Errorf("Got %#v", il)
+	expected := []string{
+		"github-issue-janitor#3",
+		"github-issue-janitor#6",
+		"github-issue-janitor#7",
+		"testrepo#346",
+	}
+	il := utils.ParseBodyForIssueLinks(sampleBody, GITHUB_ORG_NAME, "testrepo")
+	if !reflect.DeepEqual(il, expected) {
+		t.Errorf("Expected %v, got %#v", expected, il)
+	}
 }
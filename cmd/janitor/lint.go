@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// Check every reference in every epic, and list the ones that don't
+// point at an open issue the janitor looks after: issues that don't
+// exist or are closed, issues in archived or ignored repos or in other
+// orgs, and pull requests.
+func lintEpics(ctx context.Context, client *gh.Client, issuesInEpics *epicIndex, knownIssues *issueCache, archivedRepos map[string]struct{}) {
+	for _, tag := range issuesInEpics.hierarchy().Containers() {
+		findings := []string{}
+
+		for _, mi := range issuesInEpics.members[tag] {
+			repo, _, err := utils.ParseIssueTag(mi)
+			if err != nil {
+				fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			facts := utils.RefFacts{}
+			_, facts.IgnoredRepo = GITHUB_IGNORED_REPOS[repo]
+			_, facts.ArchivedRepo = archivedRepos[repo]
+			if !facts.IgnoredRepo && !facts.ArchivedRepo {
+				issue := knownIssues.get(ctx, client, mi)
+				if issue == nil {
+					facts.Missing = true
+				} else {
+					facts.PullRequest = issue.IsPullRequest()
+					facts.Closed = issue.GetState() == "closed"
+				}
+			}
+
+			if finding := utils.LintRef(mi, facts); finding != "" {
+				findings = append(findings, finding)
+			}
+		}
+
+		body := utils.StripManagedBlock(issuesInEpics.epics[tag].GetBody(), utils.ProgressBlockStart, utils.ProgressBlockEnd)
+		for _, link := range utils.ParseBodyForOtherOrgLinks(body, GITHUB_ORG_NAME) {
+			findings = append(findings, fmt.Sprintf("%s is in another org", link))
+		}
+
+		if len(findings) > 0 {
+			fmt.Printf("Epic %s has %d problems:\n", tag, len(findings))
+			for _, f := range findings {
+				fmt.Printf("- %s\n", f)
+			}
+		}
+	}
+}
@@ -8,132 +8,70 @@ import (
 
 	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
 	gh "github.com/google/go-github/github"
-	"golang.org/x/oauth2"
 )
 
 const GITHUB_ORG_NAME = "dotmesh-io"
 const GITHUB_TRIAGE_COLUMN = 1527643
 
 func main() {
-	// Ignore these repos
-	GITHUB_IGNORED_REPOS := map[string]struct{}{
-		"roadmap":             struct{}{},
-		"moby-counter-issues": struct{}{},
-	}
-
-	// Ignore issues with these labels
-	GITHUB_IGNORED_LABELS := map[string]struct{}{
-		"hypothesis": struct{}{},
-		"bot":        struct{}{},
-	}
-
-	// Labels that should be renamed. Renames happen *before*
-	// DESIRED_LABELS / UNDESIRED_LABELS are considered.
-	RENAME_THESE_LABELS := map[string]string{
-		/* Proposed rename to make it clear you pick exactly one of these (and to make them sort consistently against importance:* and urgency:*
-
-		If we adopt this, update the corresponding names in DESIRED_LABELS.
-
-		"task":  "type:task",
-		"bug":   "type:bug",
-		"debt":  "type:debt",
-		"epic":  "type:epic",
-		"theme": "type:theme",
-		*/
-	}
-
-	// Labels we want in every repo, with their colours
-	DESIRED_LABELS := map[string]string{
-		// Issue types
-		"task":  "84b6eb",
-		"bug":   "f03838",
-		"debt":  "dbba69",
-		"epic":  "7744aa",
-		"theme": "7744aa",
-		"bot":   "EEF5DB",
-
-		// Flag to mark an issue as coming from the support team, so they
-		// can find them and update users
-		"support": "77f252",
-
-		// Urgency: How soon do we need it?
-		"urgency:high":   "c40000",
-		"urgency:medium": "ffff00",
-		"urgency:low":    "00ba00",
-
-		// Importance: How much do we need it?
-		"importance:high":   "ffaaaa",
-		"importance:medium": "ffffaa",
-		"importance:low":    "aaffaa",
-	}
-
-	// Labels we want to delete if found
-	UNDESIRED_LABELS := map[string]struct{}{
-		"P0":                 struct{}{},
-		"P1":                 struct{}{},
-		"P2":                 struct{}{},
-		"P3":                 struct{}{},
-		"code-review":        struct{}{},
-		"ready-for-sign-off": struct{}{},
-		"duplicate":          struct{}{},
-		"enhancement":        struct{}{},
-		"good first issue":   struct{}{},
-		"help wanted":        struct{}{},
-		"invalid":            struct{}{},
-		"question":           struct{}{},
-		"wontfix":            struct{}{},
-	}
-
-	// Labels that make an issue count as an epic
-	EPIC_LABELS := map[string]struct{}{
-		"epic":  struct{}{},
-		"theme": struct{}{},
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "audit-boards":
+			auditBoards(os.Args[2:])
+		case "tree":
+			printEpicTree(os.Args[2:])
+		case "why":
+			explainIssue(os.Args[2:])
+		default:
+			fmt.Printf("USAGE: %s [audit-boards [-archive] | tree [-markdown] | why repo#number]\n", os.Args[0])
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Set to true to prevent any actual changes happening on Github
-	DRY_RUN := false
+	housekeeping()
+}
 
+// Do our regular tidying up of every repo and board
+func housekeeping() {
 	if DRY_RUN {
 		fmt.Printf("DRY RUN MODE - not actually changing anything!\n")
 	}
 
+	// Read any rules and scripts first, so mistakes in them show up
+	// straight away
+	rules := loadRules()
+	scripts := loadScripts()
+
 	ctx := context.Background()
+	client := newGithubClient(ctx)
 
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_AUTH_TOKEN")},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client := gh.NewClient(tc)
+	allRepos := listOrgRepos(ctx, client)
 
-	opt := &gh.RepositoryListByOrgOptions{
-		Type:        "all",
-		ListOptions: gh.ListOptions{PerPage: 500},
-	}
-	
-	var allRepos []*gh.Repository
+	// Maps we build up as we scan through every issue in every repo:
 
-	for {
-		repos, resp, err := client.Repositories.ListByOrg(ctx, GITHUB_ORG_NAME, opt)
-		if err != nil {
-				fmt.Printf("Error fetching repositories: %+v\n", err)
-				os.Exit(1)
-		}
+	// repo#number strings -> github IDs for issues that aren't in projects
+	issuesNotInProjects := map[string]int64{}
 
-		allRepos = append(allRepos, repos...)
-		if resp.NextPage == 0 {
-			break
-		}
-		opt.Page = resp.NextPage
+	// Issues that are in epics, either by being mentioned in them or by
+	// being linked to them in the Github UI
+	issuesInEpics := newEpicIndex()
 
-	}
+	// repo#number strings for every open issue and pull request
+	openIssues := map[string]struct{}{}
 
-	// Maps we build up as we scan through every issue in every repo:
+	// Every open issue and pull request, and any other issues we have to
+	// look up along the way
+	knownIssues := newIssueCache()
 
-	// repo#number strings -> github IDs for issues that aren't in projects
-	issuesNotInProjects := map[string]int64{}
+	// repo#number strings for issues that are mentioned by open pull requests
+	issuesWithOpenPRs := map[string]struct{}{}
 
-	// repo#number strings for issues that are mentioned in epics
-	issuesMentionedInEpics := map[string]struct{}{}
+	// Names of the repos we scan
+	scannedRepos := []string{}
+
+	// Names of archived repos
+	archivedRepos := map[string]struct{}{}
 
 	// Scan through every repo
 	
@@ -142,12 +80,18 @@ skipRepo:
 		rn := *(repo.Name)
 		fmt.Printf("### EXAMINING REPO %d/%d: %s\n", idx+1, len(allRepos), rn)
 
+		if *repo.Archived {
+			archivedRepos[rn] = struct{}{}
+		}
+
 		_, ignoredRepo := GITHUB_IGNORED_REPOS[rn]
 		if ignoredRepo || *repo.Archived {
 			fmt.Printf("Ignoring that one!\n")
 			continue skipRepo
 		}
 
+		scannedRepos = append(scannedRepos, rn)
+
 		// Process labels in this repo
 
 		labels, _, err := client.Issues.ListLabels(ctx, GITHUB_ORG_NAME, rn, &gh.ListOptions{})
@@ -233,6 +177,9 @@ skipRepo:
 			}
 		}
 
+		// Process milestones in this repo
+		syncMilestones(ctx, client, rn, CLOSE_PAST_DUE_MILESTONES, DRY_RUN)
+
 		// Find all the epics in the repo
 		page := 1
 		for {
@@ -259,7 +206,16 @@ skipRepo:
 		skipIssue:
 			for _, issue := range issues.Issues {
 				issueTag := fmt.Sprintf("%s#%d", rn, *(issue.Number))
-				isEpic := false
+				epicKind := ""
+
+				openIssues[issueTag] = struct{}{}
+				knownIssues.add(issueTag, issue)
+
+				if issue.IsPullRequest() && issue.Body != nil {
+					for _, mi := range utils.ParseBodyForIssueLinks(*(issue.Body), GITHUB_ORG_NAME, rn) {
+						issuesWithOpenPRs[repoNames.Tag(mi)] = struct{}{}
+					}
+				}
 
 				for _, label := range issue.Labels {
 					_, ignoredLabel := GITHUB_IGNORED_LABELS[*(label.Name)]
@@ -267,21 +223,18 @@ skipRepo:
 						fmt.Printf("Ignoring issue %s due to label %s\n", issueTag, *(label.Name))
 						continue skipIssue
 					}
-					_, isEpicLabel := EPIC_LABELS[*(label.Name)]
-					if isEpicLabel {
-						isEpic = true
+					kind, isEpicLabel := EPIC_LABELS[*(label.Name)]
+					if isEpicLabel && epicKind == "" {
+						epicKind = kind
 					}
 				}
 
-				// Find the issues mentioned in the epic
-				if isEpic {
-					body := *(issue.Body)
+				// Label it according to what it says and who wrote it
+				applyLabelRules(ctx, client, rn, knownIssues.issues[issueTag], DRY_RUN)
 
-					mentionedIssues := utils.ParseBodyForIssueLinks(body, GITHUB_ORG_NAME, rn)
-					fmt.Printf("Issue %s is an epic, mentioning these issues: %v!\n", issueTag, mentionedIssues)
-					for _, mi := range mentionedIssues {
-						issuesMentionedInEpics[mi] = struct{}{}
-					}
+				// Find the issues mentioned in the epic
+				if epicKind != "" {
+					issuesInEpics.addEpic(ctx, client, rn, issue, epicKind)
 				}
 			}
 
@@ -343,16 +296,49 @@ skipRepo:
 		}
 	} // End of iteration over all repos
 
-	// Now we have built up two maps: issuesNotInProjects and
-	// issuesMentionedIn Epics. Process them to find issues not in a
-	// project or epic, so we can put them in the triage column.
+	// Now we have built up issuesNotInProjects and issuesInEpics.
+	// Process them to find issues not in a project or epic, so we can
+	// put them in the triage column.
+
+	// Check the epics form a sensible hierarchy
+	reportHierarchyProblems(issuesInEpics.hierarchy())
+
+	// Epics can still mention issues by the repo#number they had before
+	// being transferred to another repo, so if anything looks like it's
+	// not in an epic, compare node IDs (which survive transfers) too.
+	for tag, _ := range issuesNotInProjects {
+		_, _, inEpic := issuesInEpics.lookup(tag, knownIssues.knownNodeID(tag))
+		if !inEpic {
+			issuesInEpics.resolveMentions(ctx, client, knownIssues)
+			break
+		}
+	}
 
 	// Now put the issues not in projects into the triage column, unless they're in an epic
 	for tag, id := range issuesNotInProjects {
-		_, mentionedInEpic := issuesMentionedInEpics[tag]
-		if mentionedInEpic {
-			fmt.Printf("Issue %s is mentioned in an epic, so isn't lost\n", tag)
+		knownAs, epic, inEpic := issuesInEpics.lookup(tag, knownIssues.knownNodeID(tag))
+		if inEpic && knownAs == tag {
+			fmt.Printf("Issue %s is in epic %s, so isn't lost\n", tag, epic)
+		} else if inEpic {
+			fmt.Printf("Issue %s is in epic %s as %s, from before it was transferred, so isn't lost\n", tag, epic, knownAs)
 		} else {
+			// Work out what to say about it first, so it doesn't end up
+			// in triage unexplained if the cooldown says not now
+			var explanation *pendingComment
+			if EXPLAIN_TRIAGE {
+				repo, number, err := utils.ParseIssueTag(tag)
+				if err != nil {
+					fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+					os.Exit(1)
+				}
+				explanation = prepareComment(ctx, client, repo, number, "triaged", commentTemplate("triaged"), struct {
+					Epics int
+				}{len(issuesInEpics.epics)}, false)
+				if explanation == nil {
+					continue
+				}
+			}
+
 			fmt.Printf("ACTION: Issue %s isn't mentioned in an epic or a project, putting it into triage...\n", tag)
 
 			if !DRY_RUN {
@@ -372,8 +358,62 @@ skipRepo:
 					time.Sleep(delay)
 				}
 			}
+
+			if explanation != nil {
+				explanation.post(ctx, client, DRY_RUN)
+			}
 		}
 	}
 
+	// Apply the rules from the rules file and any scripts
+	applyRules(ctx, client, rules, scripts, openIssues, knownIssues, issuesNotInProjects, issuesInEpics, DRY_RUN)
+
+	// Look for epics referring to things they shouldn't
+	if LINT_EPICS {
+		lintEpics(ctx, client, issuesInEpics, knownIssues, archivedRepos)
+	}
+
+	// Look for issues waiting on things that are done, or on each other
+	if REPORT_DEPENDENCIES {
+		reportDependencies(ctx, client, openIssues, knownIssues)
+	}
+
+	// Look for issues left behind by releases
+	reportIssuesOnClosedMilestones(openIssues, knownIssues)
+
+	// Look for issues that are about the same thing
+	reportDuplicates(ctx, client, DUPLICATE_DETECTION, openIssues, knownIssues, DRY_RUN)
+
+	// Make epics' checkboxes match their issues
+	if SYNC_EPIC_CHECKBOXES {
+		syncEpicCheckboxes(ctx, client, issuesInEpics, knownIssues, DRY_RUN)
+	}
+
+	// Work out how far along each epic is
+	trackEpicProgress(ctx, client, issuesInEpics, knownIssues, DRY_RUN)
+
+	// Wrap up epics that are finished
+	closeFinishedEpics(ctx, client, issuesInEpics, knownIssues, DRY_RUN)
+
+	// Deal with issues and pull requests nobody has touched in a while
+	manageStaleIssues(ctx, client, openIssues, knownIssues, DRY_RUN)
+	manageStalePullRequests(ctx, client, openIssues, knownIssues, DRY_RUN)
+
+	// Make sure the right people are working on things
+	checkAssignees(ctx, client, ASSIGNEE_RULES, openIssues, knownIssues, DRY_RUN)
+
+	// Chase up issues that have been waiting in triage for too long
+	escalateTriage(ctx, client, TRIAGE_ESCALATION, knownIssues, DRY_RUN)
+
+	// Tidy up the boards we have rules for
+	applyBoardRules(ctx, client, BOARD_RULES, openIssues, issuesWithOpenPRs, knownIssues, DRY_RUN)
+
+	// Make sure every issue lives on just one board
+	allProjects := listOrgProjects(ctx, client)
+	for _, rn := range scannedRepos {
+		allProjects = append(allProjects, listRepoProjects(ctx, client, rn)...)
+	}
+	fixCardsOnMultipleBoards(ctx, client, allProjects, PROJECT_PRIORITY, DRY_RUN)
+
 	fmt.Printf("Done.\n")
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	gh "github.com/google/go-github/github"
+)
+
+// DesiredMilestone is a milestone we want in every repo. A zero DueOn
+// means we don't mind what the due date is, so new milestones get none
+// and existing ones keep theirs.
+type DesiredMilestone struct {
+	Description string
+	DueOn       time.Time
+}
+
+func listMilestones(ctx context.Context, client *gh.Client, repo string) []*gh.Milestone {
+	var allMilestones []*gh.Milestone
+	opt := &gh.MilestoneListOptions{
+		State:       "all",
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		milestones, resp, err := client.Issues.ListMilestones(ctx, GITHUB_ORG_NAME, repo, opt)
+		if err != nil {
+			fmt.Printf("Error fetching milestones in %s: %+v\n", repo, err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		allMilestones = append(allMilestones, milestones...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return allMilestones
+}
+
+// Github only keeps the date of a due date, whatever time we give it, so
+// compare them by day
+func sameDueDate(a, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return a.IsZero() == b.IsZero()
+	}
+	return a.UTC().Format("2006-01-02") == b.UTC().Format("2006-01-02")
+}
+
+// Create the milestones in DESIRED_MILESTONES that are missing from a
+// repo, and fix the descriptions and due dates of the ones that aren't
+// right. Milestones that are already closed are left as they are. If
+// closePastDue is set, open milestones whose due date has passed with no
+// issues in them at all are closed.
+func syncMilestones(ctx context.Context, client *gh.Client, repo string, closePastDue bool, dryRun bool) {
+	missing := map[string]struct{}{}
+	for title, _ := range DESIRED_MILESTONES {
+		missing[title] = struct{}{}
+	}
+
+	for _, m := range listMilestones(ctx, client, repo) {
+		title := m.GetTitle()
+		delete(missing, title)
+
+		if m.GetState() != "open" {
+			continue
+		}
+
+		if closePastDue && !m.GetDueOn().IsZero() && m.GetDueOn().Before(time.Now()) && m.GetOpenIssues() == 0 && m.GetClosedIssues() == 0 {
+			fmt.Printf("ACTION: Milestone %s in %s was due on %s and is empty, closing it\n", title, repo, m.GetDueOn().Format("2006-01-02"))
+			if !dryRun {
+				closed := "closed"
+				_, resp, err := client.Issues.EditMilestone(ctx, GITHUB_ORG_NAME, repo, m.GetNumber(), &gh.Milestone{
+					State: &closed,
+				})
+				if err != nil {
+					fmt.Printf("Error closing milestone: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+			continue
+		}
+
+		desired, known := DESIRED_MILESTONES[title]
+		if !known {
+			continue
+		}
+		if m.GetDescription() == desired.Description && (desired.DueOn.IsZero() || sameDueDate(m.GetDueOn(), desired.DueOn)) {
+			continue
+		}
+
+		fmt.Printf("ACTION: Milestone %s in %s needs its description or due date updating\n", title, repo)
+		if !dryRun {
+			edit := &gh.Milestone{
+				Description: &desired.Description,
+			}
+			if !desired.DueOn.IsZero() {
+				dueOn := desired.DueOn
+				edit.DueOn = &dueOn
+			}
+			_, resp, err := client.Issues.EditMilestone(ctx, GITHUB_ORG_NAME, repo, m.GetNumber(), edit)
+			if err != nil {
+				fmt.Printf("Error updating milestone: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+	}
+
+	titles := []string{}
+	for title, _ := range missing {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	for _, title := range titles {
+		desired := DESIRED_MILESTONES[title]
+		fmt.Printf("ACTION: Milestone %s was missing from %s\n", title, repo)
+		if !dryRun {
+			t := title
+			milestone := &gh.Milestone{
+				Title:       &t,
+				Description: &desired.Description,
+			}
+			if !desired.DueOn.IsZero() {
+				dueOn := desired.DueOn
+				milestone.DueOn = &dueOn
+			}
+			_, resp, err := client.Issues.CreateMilestone(ctx, GITHUB_ORG_NAME, repo, milestone)
+			if err != nil {
+				fmt.Printf("Error creating milestone: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+	}
+}
+
+// Report open issues whose milestone has been closed, as they've either
+// been forgotten about or need moving to a later milestone. openIssues
+// are the repo#number strings of all the open issues in the org, which
+// knownIssues already has.
+func reportIssuesOnClosedMilestones(openIssues map[string]struct{}, knownIssues *issueCache) {
+	tags := []string{}
+	for tag, _ := range openIssues {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		milestone := knownIssues.issues[tag].Milestone
+		if milestone != nil && milestone.GetState() == "closed" {
+			fmt.Printf("Issue %s is still open, but its milestone %s is closed\n", tag, milestone.GetTitle())
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// FinishedEpicAction says what to do with an epic whose issues are all
+// closed
+type FinishedEpicAction string
+
+const (
+	// Leave it alone
+	IGNORE_FINISHED_EPIC FinishedEpicAction = ""
+	// Just say so in the output, like a dry run
+	REPORT_FINISHED_EPIC FinishedEpicAction = "report"
+	// Add READY_TO_CLOSE_LABEL, so a human can close it
+	LABEL_FINISHED_EPIC FinishedEpicAction = "label"
+	// Close it, with a comment saying why
+	CLOSE_FINISHED_EPIC FinishedEpicAction = "close"
+)
+
+// Tick the boxes in epics' task lists for issues that are closed, and
+// untick them for issues that are open
+func syncEpicCheckboxes(ctx context.Context, client *gh.Client, issuesInEpics *epicIndex, knownIssues *issueCache, dryRun bool) {
+	isClosed := func(tag string) (bool, bool) {
+		issue := knownIssues.get(ctx, client, repoNames.Tag(tag))
+		if issue == nil {
+			return false, false
+		}
+		return issue.GetState() == "closed", true
+	}
+
+	for _, tag := range issuesInEpics.hierarchy().Containers() {
+		repo, number, err := utils.ParseIssueTag(tag)
+		if err != nil {
+			fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		epic := issuesInEpics.epics[tag]
+		body := epic.GetBody()
+		newBody := utils.SyncCheckboxes(body, GITHUB_ORG_NAME, repo, isClosed)
+		if newBody == body {
+			continue
+		}
+
+		fmt.Printf("ACTION: Updating checkboxes in %s:\n%s", tag, utils.LineDiff(body, newBody))
+		epic.Body = &newBody
+		if !dryRun {
+			_, resp, err := client.Issues.Edit(ctx, GITHUB_ORG_NAME, repo, number, &gh.IssueRequest{
+				Body: &newBody,
+			})
+			if err != nil {
+				fmt.Printf("Error updating epic: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+	}
+}
+
+// Work out the progress of an epic from the state of the issues in it
+func epicProgress(ctx context.Context, client *gh.Client, members []string, knownIssues *issueCache) utils.EpicProgress {
+	progress := utils.EpicProgress{}
+
+	for _, tag := range members {
+		issue := knownIssues.get(ctx, client, tag)
+		if issue == nil {
+			continue
+		}
+
+		progress.Total++
+		if issue.GetState() == "closed" {
+			progress.Closed++
+			continue
+		}
+
+		for _, l := range issue.Labels {
+			if *(l.Name) == BUG_LABEL {
+				progress.OpenBugs = append(progress.OpenBugs, tag)
+			}
+		}
+		if len(issue.Assignees) == 0 {
+			progress.Unassigned = append(progress.Unassigned, tag)
+		}
+	}
+
+	return progress
+}
+
+// Report how far along every epic is and, if EPIC_PROGRESS_BLOCK is
+// set, keep the summary in each epic's body up to date
+func trackEpicProgress(ctx context.Context, client *gh.Client, issuesInEpics *epicIndex, knownIssues *issueCache, dryRun bool) {
+	for _, tag := range issuesInEpics.hierarchy().Containers() {
+		epic := issuesInEpics.epics[tag]
+		progress := epicProgress(ctx, client, issuesInEpics.members[tag], knownIssues)
+		fmt.Printf("Epic %s progress: %s\n", tag, progress.String())
+
+		if !EPIC_PROGRESS_BLOCK {
+			continue
+		}
+
+		body := epic.GetBody()
+		newBody := utils.ReplaceManagedBlock(body, utils.ProgressBlockStart, utils.ProgressBlockEnd, progress.Markdown(issueURL))
+		if newBody == body {
+			continue
+		}
+
+		fmt.Printf("ACTION: Updating progress summary in %s\n", tag)
+		epic.Body = &newBody
+		if !dryRun {
+			repo, number, err := utils.ParseIssueTag(tag)
+			if err != nil {
+				fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			_, resp, err := client.Issues.Edit(ctx, GITHUB_ORG_NAME, repo, number, &gh.IssueRequest{
+				Body: &newBody,
+			})
+			if err != nil {
+				fmt.Printf("Error updating epic: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+	}
+}
+
+// Deal with epics whose issues are all closed, as FINISHED_EPICS says
+func closeFinishedEpics(ctx context.Context, client *gh.Client, issuesInEpics *epicIndex, knownIssues *issueCache, dryRun bool) {
+	for _, tag := range issuesInEpics.hierarchy().Containers() {
+		repo, number, err := utils.ParseIssueTag(tag)
+		if err != nil {
+			fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		action, found := FINISHED_EPICS[repo]
+		if !found {
+			action = FINISHED_EPICS[""]
+		}
+		if action == IGNORE_FINISHED_EPIC {
+			continue
+		}
+
+		alreadyLabelled := false
+		for _, l := range issuesInEpics.epics[tag].Labels {
+			if l.GetName() == READY_TO_CLOSE_LABEL {
+				alreadyLabelled = true
+			}
+		}
+
+		progress := epicProgress(ctx, client, issuesInEpics.members[tag], knownIssues)
+		if progress.Total == 0 || progress.Closed < progress.Total {
+			// It's got more to do again since we labelled it
+			if action == LABEL_FINISHED_EPIC && alreadyLabelled {
+				fmt.Printf("ACTION: Epic %s has open issues again, so removing %s\n", tag, READY_TO_CLOSE_LABEL)
+				if !dryRun {
+					resp, err := client.Issues.RemoveLabelForIssue(ctx, GITHUB_ORG_NAME, repo, number, READY_TO_CLOSE_LABEL)
+					if err != nil {
+						fmt.Printf("Error unlabelling epic: %+v / %+v\n", err, resp)
+						os.Exit(1)
+					}
+					waitForRateLimit(resp)
+				}
+			}
+			continue
+		}
+
+		switch action {
+		case REPORT_FINISHED_EPIC:
+			fmt.Printf("Epic %s has all %d of its issues closed\n", tag, progress.Total)
+
+		case LABEL_FINISHED_EPIC:
+			if alreadyLabelled {
+				continue
+			}
+
+			fmt.Printf("ACTION: Epic %s has all %d of its issues closed, labelling it %s\n", tag, progress.Total, READY_TO_CLOSE_LABEL)
+			if !dryRun {
+				_, resp, err := client.Issues.AddLabelsToIssue(ctx, GITHUB_ORG_NAME, repo, number, []string{READY_TO_CLOSE_LABEL})
+				if err != nil {
+					fmt.Printf("Error labelling epic: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+
+		case CLOSE_FINISHED_EPIC:
+			// Don't close it without saying why
+			explanation := prepareComment(ctx, client, repo, number, "finished-epic", commentTemplate("finished-epic"), struct {
+				Total int
+				Kind  string
+			}{progress.Total, issuesInEpics.kinds[tag]}, false)
+			if explanation == nil {
+				continue
+			}
+
+			fmt.Printf("ACTION: Epic %s has all %d of its issues closed, closing it\n", tag, progress.Total)
+			explanation.post(ctx, client, dryRun)
+			if !dryRun {
+				closed := "closed"
+				_, resp, err := client.Issues.Edit(ctx, GITHUB_ORG_NAME, repo, number, &gh.IssueRequest{
+					State: &closed,
+				})
+				if err != nil {
+					fmt.Printf("Error closing epic: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+
+		default:
+			fmt.Printf("Error: unknown action %q for finished epics in %s\n", action, repo)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// What comments made by rules can say about the issue, as their bodies
+// are comment templates
+type ruleCommentData struct {
+	Tag    string
+	Repo   string
+	Number int
+	Title  string
+	Author string
+}
+
+// Read the rules in the file named by JANITOR_RULES_FILE, if there is
+// one. See utils.Rule for what goes in it.
+func loadRules() []utils.Rule {
+	filename := os.Getenv("JANITOR_RULES_FILE")
+	if filename == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("Error reading rules: %s\n", err.Error())
+		os.Exit(1)
+	}
+	rules, err := utils.ParseRules(data)
+	if err != nil {
+		fmt.Printf("Error in %s: %s\n", filename, err.Error())
+		os.Exit(1)
+	}
+	for _, rule := range rules {
+		for _, action := range rule.Then {
+			if action.Action != utils.ActionComment {
+				continue
+			}
+			if _, err := utils.ExpandTemplate(rule.Name, action.Body, ruleCommentData{}); err != nil {
+				fmt.Printf("Error in %s: rule %q: %s\n", filename, rule.Name, err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+
+	fmt.Printf("Loaded %d rules from %s\n", len(rules), filename)
+	return rules
+}
+
+// Load the Starlark scripts (*.star) in the directory named by
+// JANITOR_SCRIPTS_DIR, if there is one. See utils.Script for what goes
+// in them.
+func loadScripts() []*utils.Script {
+	dir := os.Getenv("JANITOR_SCRIPTS_DIR")
+	if dir == "" {
+		return nil
+	}
+
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.star"))
+	if err != nil {
+		fmt.Printf("Error finding scripts: %s\n", err.Error())
+		os.Exit(1)
+	}
+	sort.Strings(filenames)
+
+	scripts := []*utils.Script{}
+	for _, filename := range filenames {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fmt.Printf("Error reading script: %s\n", err.Error())
+			os.Exit(1)
+		}
+		script, err := utils.LoadScript(filepath.Base(filename), src, SCRIPT_TIMEOUT)
+		if err != nil {
+			fmt.Printf("Error in script %s: %s\n", filename, err.Error())
+			os.Exit(1)
+		}
+		scripts = append(scripts, script)
+	}
+
+	fmt.Printf("Loaded %d scripts from %s\n", len(scripts), dir)
+	return scripts
+}
+
+// What the rules need to know about an issue
+func issueFacts(tag string, issue *gh.Issue, inProject, inEpic bool) utils.IssueFacts {
+	repo, _, _ := utils.ParseIssueTag(tag)
+	facts := utils.IssueFacts{
+		Repo:        repo,
+		State:       issue.GetState(),
+		Created:     issue.GetCreatedAt(),
+		Updated:     issue.GetUpdatedAt(),
+		InProject:   inProject,
+		InEpic:      inEpic,
+		PullRequest: issue.IsPullRequest(),
+	}
+	for _, l := range issue.Labels {
+		facts.Labels = append(facts.Labels, l.GetName())
+	}
+	for _, u := range issue.Assignees {
+		facts.Assignees = append(facts.Assignees, u.GetLogin())
+	}
+	return facts
+}
+
+// Run the rules, then the scripts, over every open issue, apart from
+// ones with ignored labels. openIssues are the repo#number strings of all the open issues
+// and pull requests in the org, which knownIssues already has. Issues in
+// issuesNotInProjects that aren't in an epic have been put into triage
+// by now, so count as being in a project.
+func applyRules(ctx context.Context, client *gh.Client, rules []utils.Rule, scripts []*utils.Script, openIssues map[string]struct{}, knownIssues *issueCache, issuesNotInProjects map[string]int64, issuesInEpics *epicIndex, dryRun bool) {
+	if len(rules) == 0 && len(scripts) == 0 {
+		return
+	}
+
+	fmt.Printf("### APPLYING %d RULES AND %d SCRIPTS\n", len(rules), len(scripts))
+
+	tags := []string{}
+	for tag, _ := range openIssues {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	now := time.Now()
+
+skipIssue:
+	for _, tag := range tags {
+		issue := knownIssues.issues[tag]
+		for _, l := range issue.Labels {
+			if _, ignored := GITHUB_IGNORED_LABELS[l.GetName()]; ignored {
+				continue skipIssue
+			}
+		}
+
+		_, _, inEpic := issuesInEpics.lookup(tag, knownIssues.knownNodeID(tag))
+		_, notInProject := issuesNotInProjects[tag]
+		inProject := !notInProject || !inEpic
+
+		for _, rule := range rules {
+			// Earlier rules may have changed the issue
+			facts := issueFacts(tag, issue, inProject, inEpic)
+			for _, action := range rule.Plan(facts, now) {
+				if action.Action == utils.ActionComment {
+					body, err := utils.ExpandTemplate(rule.Name, action.Body, ruleCommentData{
+						Tag:    tag,
+						Repo:   facts.Repo,
+						Number: issue.GetNumber(),
+						Title:  issue.GetTitle(),
+						Author: issue.GetUser().GetLogin(),
+					})
+					if err != nil {
+						fmt.Printf("Error in rule %q: %s\n", rule.Name, err.Error())
+						os.Exit(1)
+					}
+					action.Body = body
+				}
+				runRuleAction(ctx, client, rule.Name, action, tag, issue, dryRun)
+			}
+		}
+
+		for _, script := range scripts {
+			scriptIssue := utils.ScriptIssue{
+				IssueFacts: issueFacts(tag, issue, inProject, inEpic),
+				Number:     issue.GetNumber(),
+				Title:      issue.GetTitle(),
+				Body:       issue.GetBody(),
+				Author:     issue.GetUser().GetLogin(),
+				URL:        issue.GetHTMLURL(),
+			}
+			// This is fatal even if the script only took too long, as
+			// there's no stopping it once it's started
+			actions, err := script.Check(GITHUB_ORG_NAME, scriptIssue, now)
+			if err != nil {
+				fmt.Printf("Error running script on %s: %s\n", tag, err.Error())
+				os.Exit(1)
+			}
+			for _, action := range actions {
+				runRuleAction(ctx, client, script.Name, action, tag, issue, dryRun)
+			}
+		}
+	}
+}
+
+// Do one thing a rule says to an issue, and update issue to match
+func runRuleAction(ctx context.Context, client *gh.Client, ruleName string, action utils.RuleAction, tag string, issue *gh.Issue, dryRun bool) {
+	repo, number, err := utils.ParseIssueTag(tag)
+	if err != nil {
+		fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	switch action.Action {
+	case utils.ActionAddLabel:
+		fmt.Printf("ACTION: Rule %q: labelling %s as %s\n", ruleName, tag, action.Label)
+		if !dryRun {
+			_, resp, err := client.Issues.AddLabelsToIssue(ctx, GITHUB_ORG_NAME, repo, number, []string{action.Label})
+			if err != nil {
+				fmt.Printf("Error labelling issue: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+		label := action.Label
+		issue.Labels = append(issue.Labels, gh.Label{Name: &label})
+
+	case utils.ActionRemoveLabel:
+		fmt.Printf("ACTION: Rule %q: removing label %s from %s\n", ruleName, action.Label, tag)
+		if !dryRun {
+			resp, err := client.Issues.RemoveLabelForIssue(ctx, GITHUB_ORG_NAME, repo, number, action.Label)
+			if err != nil {
+				fmt.Printf("Error unlabelling issue: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+		labels := []gh.Label{}
+		for _, l := range issue.Labels {
+			if l.GetName() != action.Label {
+				labels = append(labels, l)
+			}
+		}
+		issue.Labels = labels
+
+	case utils.ActionComment:
+		fmt.Printf("Rule %q: %s needs a comment\n", ruleName, tag)
+		postComment(ctx, client, repo, number, "rule "+ruleName, "{{.}}", action.Body, dryRun)
+
+	case utils.ActionMoveCard:
+		fmt.Printf("Rule %q: %s belongs in column %d\n", ruleName, tag, action.Column)
+		moveToColumn(ctx, client, tag, issue, action.Column, dryRun)
+
+	case utils.ActionClose:
+		fmt.Printf("ACTION: Rule %q: closing %s\n", ruleName, tag)
+		closed := "closed"
+		if !dryRun {
+			_, resp, err := client.Issues.Edit(ctx, GITHUB_ORG_NAME, repo, number, &gh.IssueRequest{
+				State: &closed,
+			})
+			if err != nil {
+				fmt.Printf("Error closing issue: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+		issue.State = &closed
+
+	case utils.ActionAssign:
+		fmt.Printf("ACTION: Rule %q: assigning %s to %s\n", ruleName, tag, strings.Join(action.Users, ", "))
+		if !dryRun {
+			_, resp, err := client.Issues.AddAssignees(ctx, GITHUB_ORG_NAME, repo, number, action.Users)
+			if err != nil {
+				fmt.Printf("Error assigning issue: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+		for _, u := range action.Users {
+			login := u
+			issue.Assignees = append(issue.Assignees, &gh.User{Login: &login})
+		}
+	}
+}
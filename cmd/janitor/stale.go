@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// StaleIssues says when issues count as stale and what happens to them.
+// Issues with no activity for MarkAfter get Label and a warning comment;
+// if nobody comments within CloseAfter, they're closed. A comment from
+// anyone but the janitor takes the label off again.
+type StaleIssues struct {
+	// Zero disables the lot
+	MarkAfter time.Duration
+
+	// Zero means stale issues are never closed
+	CloseAfter time.Duration
+
+	Label string
+
+	// Issues with any of these labels are never stale
+	ExemptLabels []string
+}
+
+// Label, warn, close or revive stale open issues, as configured for
+// their repo in STALE_ISSUES. openIssues are the repo#number strings of
+// all the open issues in the org, which knownIssues already has.
+func manageStaleIssues(ctx context.Context, client *gh.Client, openIssues map[string]struct{}, knownIssues *issueCache, dryRun bool) {
+	tags := []string{}
+	for tag, _ := range openIssues {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	now := time.Now()
+
+	for _, tag := range tags {
+		issue := knownIssues.issues[tag]
+		if issue.IsPullRequest() {
+			continue
+		}
+
+		repo, number, err := utils.ParseIssueTag(tag)
+		if err != nil {
+			fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		config, found := STALE_ISSUES[repo]
+		if !found {
+			config = STALE_ISSUES[""]
+		}
+		if config.MarkAfter == 0 || config.Label == "" {
+			continue
+		}
+
+		labelled, exempt := false, false
+		for _, l := range issue.Labels {
+			name := l.GetName()
+			if name == config.Label {
+				labelled = true
+			}
+			if _, ignored := GITHUB_IGNORED_LABELS[name]; ignored {
+				exempt = true
+			}
+			for _, e := range config.ExemptLabels {
+				if name == e {
+					exempt = true
+				}
+			}
+		}
+		if exempt {
+			continue
+		}
+
+		// Only issues we've marked need their comments looking at
+		var markedAt time.Time
+		active := false
+		if labelled {
+			markedAt, active = staleSince(ctx, client, repo, number)
+		}
+
+		action := utils.NextStaleAction(now, issue.GetUpdatedAt(), markedAt, active, config.MarkAfter, config.CloseAfter)
+		days := int(now.Sub(issue.GetUpdatedAt()).Hours() / 24)
+
+		switch action {
+		case utils.StaleMark:
+			// The close countdown starts from the warning, so don't
+			// label the issue until it can be given one
+			warning := prepareComment(ctx, client, repo, number, "stale", commentTemplate("stale-issue"), struct {
+				Days        int
+				Label       string
+				CloseInDays int
+			}{days, config.Label, int(config.CloseAfter.Hours() / 24)}, true)
+			if warning == nil {
+				continue
+			}
+
+			if labelled {
+				// Somebody else labelled it, so we just need to warn
+				fmt.Printf("Warning that %s is %s\n", tag, config.Label)
+			} else {
+				fmt.Printf("ACTION: Labelling %s as %s after %d days of inactivity\n", tag, config.Label, days)
+				if !dryRun {
+					_, resp, err := client.Issues.AddLabelsToIssue(ctx, GITHUB_ORG_NAME, repo, number, []string{config.Label})
+					if err != nil {
+						fmt.Printf("Error labelling issue: %+v / %+v\n", err, resp)
+						os.Exit(1)
+					}
+					waitForRateLimit(resp)
+				}
+			}
+
+			warning.post(ctx, client, dryRun)
+
+		case utils.StaleUnmark:
+			fmt.Printf("ACTION: %s has been commented on, so it isn't %s any more\n", tag, config.Label)
+			if !dryRun {
+				resp, err := client.Issues.RemoveLabelForIssue(ctx, GITHUB_ORG_NAME, repo, number, config.Label)
+				if err != nil {
+					fmt.Printf("Error unlabelling issue: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+
+		case utils.StaleClose:
+			fmt.Printf("ACTION: Closing %s, which has been %s for %d days\n", tag, config.Label, int(now.Sub(markedAt).Hours()/24))
+			if !dryRun {
+				closed := "closed"
+				_, resp, err := client.Issues.Edit(ctx, GITHUB_ORG_NAME, repo, number, &gh.IssueRequest{
+					State: &closed,
+				})
+				if err != nil {
+					fmt.Printf("Error closing issue: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+		}
+	}
+}
+
+// Find when the janitor last warned that an issue was stale, and
+// whether anybody else has commented since. Returns the zero time if it
+// never has. The warning is updated or replaced whenever an issue is
+// marked as stale (see prepareComment's renew), and at no other time, so
+// it's when it was last updated that counts.
+func staleSince(ctx context.Context, client *gh.Client, repo string, number int) (time.Time, bool) {
+	comments := listIssueComments(ctx, client, repo, number)
+	warning, _ := findOwnComment(ctx, client, comments, "stale")
+	if warning == nil {
+		return time.Time{}, false
+	}
+	markedAt := warning.GetUpdatedAt()
+
+	me := getJanitorLogin(ctx, client)
+	for _, c := range comments {
+		if c.GetUser().GetLogin() != me && c.GetCreatedAt().After(markedAt) {
+			return markedAt, true
+		}
+	}
+	return markedAt, false
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// StalePullRequests says when pull requests count as stale and what
+// happens to them. Pull requests with no commits or reviews for
+// MarkAfter get Label and a comment pinging their author and requested
+// reviewers. New commits, reviews or comments take the label off again.
+type StalePullRequests struct {
+	// Zero disables the lot
+	MarkAfter time.Duration
+
+	Label string
+
+	// Close draft pull requests that have been stale for this long.
+	// Zero means they're never closed; ready pull requests never are.
+	CloseDraftsAfter time.Duration
+
+	// Pull requests with any of these labels are never stale
+	ExemptLabels []string
+}
+
+// The bits of a pull request go-github doesn't know about yet
+type pullRequestDetails struct {
+	Draft              bool       `json:"draft"`
+	CreatedAt          time.Time  `json:"created_at"`
+	User               *gh.User   `json:"user"`
+	RequestedReviewers []*gh.User `json:"requested_reviewers"`
+	RequestedTeams     []*gh.Team `json:"requested_teams"`
+}
+
+func getPullRequestDetails(ctx context.Context, client *gh.Client, repo string, number int) *pullRequestDetails {
+	req, err := client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/pulls/%d", GITHUB_ORG_NAME, repo, number), nil)
+	if err != nil {
+		fmt.Printf("Error fetching pull request %s#%d: %+v\n", repo, number, err)
+		os.Exit(1)
+	}
+	req.Header.Set("Accept", "application/vnd.github.shadow-cat-preview+json")
+
+	details := &pullRequestDetails{}
+	resp, err := client.Do(ctx, req, details)
+	if err != nil {
+		fmt.Printf("Error fetching pull request %s#%d: %+v\n", repo, number, err)
+		os.Exit(1)
+	}
+	waitForRateLimit(resp)
+	return details
+}
+
+// When a pull request last had a commit or review, or when it was
+// opened if it's had neither
+func lastPullRequestActivity(ctx context.Context, client *gh.Client, repo string, number int, created time.Time) time.Time {
+	last := created
+
+	opt := &gh.ListOptions{PerPage: 100}
+	for {
+		commits, resp, err := client.PullRequests.ListCommits(ctx, GITHUB_ORG_NAME, repo, number, opt)
+		if err != nil {
+			fmt.Printf("Error fetching commits on %s#%d: %+v\n", repo, number, err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		for _, c := range commits {
+			if date := c.GetCommit().GetCommitter().GetDate(); date.After(last) {
+				last = date
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	opt = &gh.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := client.PullRequests.ListReviews(ctx, GITHUB_ORG_NAME, repo, number, opt)
+		if err != nil {
+			fmt.Printf("Error fetching reviews on %s#%d: %+v\n", repo, number, err)
+			os.Exit(1)
+		}
+		waitForRateLimit(resp)
+
+		for _, r := range reviews {
+			if date := r.GetSubmittedAt(); date.After(last) {
+				last = date
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return last
+}
+
+// Label, ping, close or revive stale open pull requests, as configured
+// for their repo in STALE_PULL_REQUESTS. openIssues are the repo#number
+// strings of all the open issues and pull requests in the org, which
+// knownIssues already has.
+func manageStalePullRequests(ctx context.Context, client *gh.Client, openIssues map[string]struct{}, knownIssues *issueCache, dryRun bool) {
+	tags := []string{}
+	for tag, _ := range openIssues {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	now := time.Now()
+
+	for _, tag := range tags {
+		issue := knownIssues.issues[tag]
+		if !issue.IsPullRequest() {
+			continue
+		}
+
+		repo, number, err := utils.ParseIssueTag(tag)
+		if err != nil {
+			fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		config, found := STALE_PULL_REQUESTS[repo]
+		if !found {
+			config = STALE_PULL_REQUESTS[""]
+		}
+		if config.MarkAfter == 0 || config.Label == "" {
+			continue
+		}
+
+		labelled, exempt := false, false
+		for _, l := range issue.Labels {
+			name := l.GetName()
+			if name == config.Label {
+				labelled = true
+			}
+			if _, ignored := GITHUB_IGNORED_LABELS[name]; ignored {
+				exempt = true
+			}
+			for _, e := range config.ExemptLabels {
+				if name == e {
+					exempt = true
+				}
+			}
+		}
+		if exempt {
+			continue
+		}
+
+		// Anything touched recently can't be stale, so don't spend API
+		// calls on it
+		if !labelled && now.Sub(issue.GetUpdatedAt()) < config.MarkAfter {
+			continue
+		}
+
+		details := getPullRequestDetails(ctx, client, repo, number)
+		lastActivity := lastPullRequestActivity(ctx, client, repo, number, details.CreatedAt)
+
+		var markedAt time.Time
+		active := false
+		if labelled {
+			markedAt, active = staleSince(ctx, client, repo, number)
+			if !markedAt.IsZero() && lastActivity.After(markedAt) {
+				active = true
+			}
+		}
+
+		closeAfter := time.Duration(0)
+		if details.Draft {
+			closeAfter = config.CloseDraftsAfter
+		}
+
+		action := utils.NextStaleAction(now, lastActivity, markedAt, active, config.MarkAfter, closeAfter)
+		days := int(now.Sub(lastActivity).Hours() / 24)
+
+		switch action {
+		case utils.StaleMark:
+			mentions := []string{"@" + details.User.GetLogin()}
+			for _, u := range details.RequestedReviewers {
+				mentions = append(mentions, "@"+u.GetLogin())
+			}
+			for _, t := range details.RequestedTeams {
+				mentions = append(mentions, "@"+GITHUB_ORG_NAME+"/"+t.GetSlug())
+			}
+
+			// The close countdown starts from the warning, so don't
+			// label the pull request until it can be given one
+			warning := prepareComment(ctx, client, repo, number, "stale", commentTemplate("stale-pull-request"), struct {
+				Mentions    string
+				Days        int
+				Label       string
+				CloseInDays int
+			}{strings.Join(mentions, " "), days, config.Label, int(closeAfter.Hours() / 24)}, true)
+			if warning == nil {
+				continue
+			}
+
+			if labelled {
+				fmt.Printf("Pinging %s about %s, which is %s\n", strings.Join(mentions, " "), tag, config.Label)
+			} else {
+				fmt.Printf("ACTION: Labelling %s as %s after %d days without commits or reviews\n", tag, config.Label, days)
+				if !dryRun {
+					_, resp, err := client.Issues.AddLabelsToIssue(ctx, GITHUB_ORG_NAME, repo, number, []string{config.Label})
+					if err != nil {
+						fmt.Printf("Error labelling pull request: %+v / %+v\n", err, resp)
+						os.Exit(1)
+					}
+					waitForRateLimit(resp)
+				}
+			}
+
+			warning.post(ctx, client, dryRun)
+
+		case utils.StaleUnmark:
+			fmt.Printf("ACTION: %s has had some activity, so it isn't %s any more\n", tag, config.Label)
+			if !dryRun {
+				resp, err := client.Issues.RemoveLabelForIssue(ctx, GITHUB_ORG_NAME, repo, number, config.Label)
+				if err != nil {
+					fmt.Printf("Error unlabelling pull request: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+
+		case utils.StaleClose:
+			fmt.Printf("ACTION: Closing abandoned draft %s, which has been %s for %d days\n", tag, config.Label, int(now.Sub(markedAt).Hours()/24))
+			if !dryRun {
+				closed := "closed"
+				_, resp, err := client.PullRequests.Edit(ctx, GITHUB_ORG_NAME, repo, number, &gh.PullRequest{
+					State: &closed,
+				})
+				if err != nil {
+					fmt.Printf("Error closing pull request: %+v / %+v\n", err, resp)
+					os.Exit(1)
+				}
+				waitForRateLimit(resp)
+			}
+		}
+	}
+}
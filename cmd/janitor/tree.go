@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// Find every epic and theme, and print them out as a tree, followed by
+// anything wrong with how they fit together.
+func printEpicTree(args []string) {
+	fs := flag.NewFlagSet("tree", flag.ExitOnError)
+	markdown := fs.Bool("markdown", false, "print the tree as a Markdown list of links")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	client := newGithubClient(ctx)
+
+	issuesInEpics := findEpics(ctx, client)
+	h := issuesInEpics.hierarchy()
+
+	fmt.Printf("### EPIC TREE\n")
+	fmt.Printf("%s", h.Render(*markdown, issueURL))
+
+	fmt.Printf("### PROBLEMS\n")
+	reportHierarchyProblems(h)
+
+	fmt.Printf("Done.\n")
+}
+
+// Index every open epic in the repos we look after, without looking at
+// anything else
+func findEpics(ctx context.Context, client *gh.Client) *epicIndex {
+	epicLabels := []string{}
+	for label, _ := range EPIC_LABELS {
+		epicLabels = append(epicLabels, label)
+	}
+	sort.Strings(epicLabels)
+
+	issuesInEpics := newEpicIndex()
+
+skipRepo:
+	for _, repo := range listOrgRepos(ctx, client) {
+		rn := *(repo.Name)
+
+		_, ignoredRepo := GITHUB_IGNORED_REPOS[rn]
+		if ignoredRepo || *repo.Archived {
+			continue skipRepo
+		}
+
+		query := fmt.Sprintf("is:open repo:%s/%s label:%s", GITHUB_ORG_NAME, rn, strings.Join(epicLabels, ","))
+
+	skipIssue:
+		for _, issue := range searchIssues(ctx, client, query) {
+			epicKind := ""
+			for _, label := range issue.Labels {
+				_, ignoredLabel := GITHUB_IGNORED_LABELS[*(label.Name)]
+				if ignoredLabel {
+					continue skipIssue
+				}
+				kind, isEpicLabel := EPIC_LABELS[*(label.Name)]
+				if isEpicLabel && epicKind == "" {
+					epicKind = kind
+				}
+			}
+
+			if epicKind != "" {
+				issuesInEpics.addEpic(ctx, client, rn, issue, epicKind)
+			}
+		}
+	}
+
+	return issuesInEpics
+}
+
+// The web page for an issue, given its repo#number string
+func issueURL(tag string) string {
+	repo, number, err := utils.ParseIssueTag(tag)
+	if err != nil {
+		fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return fmt.Sprintf("https://github.com/%s/%s/issues/%d", GITHUB_ORG_NAME, repo, number)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// TriageEscalation says what to do about issues whose cards have sat in
+// the triage column for longer than MaxAge. Any combination of the
+// actions can be enabled; a zero MaxAge disables the lot.
+type TriageEscalation struct {
+	MaxAge time.Duration
+
+	// Label to add to overdue issues, if not empty. Issues that already
+	// have it have been escalated before, and are left alone apart from
+	// appearing in the digest.
+	Label string
+
+	// Team to @-mention in a comment on overdue issues (eg
+	// "dotmesh-io/engineering"), if not empty. The comment is only made
+	// when the label is added, so this needs Label to be set too.
+	MentionTeam string
+
+	// Print a Markdown list of every overdue issue at the end of the run
+	Digest bool
+}
+
+// Find open issues that have been in the triage column for too long,
+// and escalate them as configured.
+func escalateTriage(ctx context.Context, client *gh.Client, escalation TriageEscalation, knownIssues *issueCache, dryRun bool) {
+	if escalation.MaxAge == 0 {
+		return
+	}
+
+	if escalation.MentionTeam != "" && escalation.Label == "" {
+		fmt.Printf("Error: triage escalation can't mention %s without a label to mark escalated issues\n", escalation.MentionTeam)
+		os.Exit(1)
+	}
+
+	fmt.Printf("### CHECKING TRIAGE COLUMN FOR ISSUES OLDER THAN %s\n", escalation.MaxAge.String())
+
+	digest := []string{}
+
+	for _, card := range listColumnCards(ctx, client, GITHUB_TRIAGE_COLUMN) {
+		if card.ContentURL == nil {
+			continue
+		}
+
+		age := time.Since(card.CreatedAt.Time)
+		if age < escalation.MaxAge {
+			continue
+		}
+
+		_, repo, number, err := utils.ParseContentURL(*(card.ContentURL))
+		if err != nil {
+			fmt.Printf("Error parsing card content: %s\n", err.Error())
+			os.Exit(1)
+		}
+		tag := fmt.Sprintf("%s#%d", repo, number)
+
+		issue := knownIssues.get(ctx, client, tag)
+		if issue == nil {
+			fmt.Printf("The card for %s in triage is for an issue that doesn't exist any more\n", tag)
+			continue
+		}
+		if issue.GetState() != "open" {
+			continue
+		}
+
+		days := int(age.Hours() / 24)
+		fmt.Printf("Issue %s has been in triage for %d days: %s\n", tag, days, *(issue.Title))
+		digest = append(digest, fmt.Sprintf("- [ ] [%s](%s): %s (%d days)", tag, *(issue.HTMLURL), *(issue.Title), days))
+
+		if escalation.Label == "" {
+			continue
+		}
+
+		alreadyEscalated := false
+		for _, l := range issue.Labels {
+			if *(l.Name) == escalation.Label {
+				alreadyEscalated = true
+			}
+		}
+		if alreadyEscalated {
+			continue
+		}
+
+		// Don't label it as escalated without telling the team
+		var mention *pendingComment
+		if escalation.MentionTeam != "" {
+			mention = prepareComment(ctx, client, repo, number, "triage", commentTemplate("triage-overdue"), struct {
+				Team string
+				Days int
+			}{escalation.MentionTeam, days}, false)
+			if mention == nil {
+				continue
+			}
+		}
+
+		fmt.Printf("ACTION: Labelling %s as %s\n", tag, escalation.Label)
+		if !dryRun {
+			_, resp, err := client.Issues.AddLabelsToIssue(ctx, GITHUB_ORG_NAME, repo, number, []string{escalation.Label})
+			if err != nil {
+				fmt.Printf("Error labelling issue: %+v / %+v\n", err, resp)
+				os.Exit(1)
+			}
+			waitForRateLimit(resp)
+		}
+
+		if mention != nil {
+			mention.post(ctx, client, dryRun)
+		}
+	}
+
+	if escalation.Digest && len(digest) > 0 {
+		fmt.Printf("### TRIAGE DIGEST: %d issues waiting more than %s\n", len(digest), escalation.MaxAge.String())
+		for _, line := range digest {
+			fmt.Printf("%s\n", line)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dotmesh-io/github-issue-janitor/pkg/utils"
+	gh "github.com/google/go-github/github"
+)
+
+// Explain what the janitor makes of one issue: whether it's ignored,
+// which project boards it's on and which epics mention it, and so
+// whether it would be put into triage.
+func explainIssue(args []string) {
+	if len(args) != 1 {
+		fmt.Printf("USAGE: %s why repo#number\n", os.Args[0])
+		os.Exit(1)
+	}
+	tag := args[0]
+	rn, number, err := utils.ParseIssueTag(tag)
+	if err != nil {
+		fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	client := newGithubClient(ctx)
+
+	// Reasons the janitor leaves the issue alone altogether
+	ignoredBecause := []string{}
+
+	var repo *gh.Repository
+	for _, r := range listOrgRepos(ctx, client) {
+		if strings.EqualFold(r.GetName(), rn) {
+			repo = r
+		}
+	}
+	if repo == nil {
+		fmt.Printf("There's no repo called %s in %s\n", rn, GITHUB_ORG_NAME)
+		os.Exit(1)
+	}
+
+	// Use the repo's real name from here on, so the tag matches the
+	// ones epics are indexed by
+	rn = repo.GetName()
+	tag = fmt.Sprintf("%s#%d", rn, number)
+
+	if _, ignoredRepo := GITHUB_IGNORED_REPOS[rn]; ignoredRepo {
+		ignoredBecause = append(ignoredBecause, fmt.Sprintf("the repo %s is in GITHUB_IGNORED_REPOS", rn))
+	}
+	if repo.GetArchived() {
+		ignoredBecause = append(ignoredBecause, fmt.Sprintf("the repo %s is archived", rn))
+	}
+
+	issue, resp, err := client.Issues.Get(ctx, GITHUB_ORG_NAME, rn, number)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone) {
+			fmt.Printf("Issue %s doesn't exist\n", tag)
+			os.Exit(1)
+		}
+		fmt.Printf("Error fetching issue %s: %+v\n", tag, err)
+		os.Exit(1)
+	}
+	waitForRateLimit(resp)
+
+	fmt.Printf("### %s: %s\n", tag, issue.GetTitle())
+	fmt.Printf("%s\n", issue.GetHTMLURL())
+
+	if issue.GetState() != "open" {
+		ignoredBecause = append(ignoredBecause, "it's closed")
+	}
+	for _, label := range issue.Labels {
+		if _, ignoredLabel := GITHUB_IGNORED_LABELS[label.GetName()]; ignoredLabel {
+			ignoredBecause = append(ignoredBecause, fmt.Sprintf("it's labelled %s, which is in GITHUB_IGNORED_LABELS", label.GetName()))
+		}
+	}
+
+	// An issue can only be on the org's boards or its own repo's
+	fmt.Printf("### PROJECT BOARDS\n")
+	projects := append(listOrgProjects(ctx, client), listRepoProjects(ctx, client, rn)...)
+	boards := 0
+	for _, project := range projects {
+		for _, column := range listProjectColumns(ctx, client, project.GetID()) {
+			for _, card := range listColumnCards(ctx, client, column.GetID()) {
+				if card.GetContentURL() != issue.GetURL() {
+					continue
+				}
+				where := fmt.Sprintf("%s/%s", project.GetName(), column.GetName())
+				if column.GetID() == GITHUB_TRIAGE_COLUMN {
+					where += " (the triage column)"
+				}
+				fmt.Printf("- %s\n", where)
+				boards++
+			}
+		}
+	}
+	if boards == 0 {
+		fmt.Printf("Not on any of the %d project boards it could be on\n", len(projects))
+	}
+
+	fmt.Printf("### EPICS\n")
+	issuesInEpics := findEpics(ctx, client)
+	epics := explainEpics(ctx, client, issuesInEpics, tag, issue.GetNodeID())
+	for _, e := range epics {
+		fmt.Printf("- %s\n", e)
+	}
+	if len(epics) == 0 {
+		fmt.Printf("Not referenced by any epic or theme (checked %d epics)\n", len(issuesInEpics.epics))
+	}
+
+	fmt.Printf("### VERDICT\n")
+	switch {
+	case len(ignoredBecause) > 0:
+		for _, why := range ignoredBecause {
+			fmt.Printf("The janitor ignores %s because %s\n", tag, why)
+		}
+	case boards > 0:
+		fmt.Printf("%s is on a project board, so it won't be put into triage\n", tag)
+	case len(epics) > 0:
+		fmt.Printf("%s is in an epic, so it isn't lost and won't be put into triage\n", tag)
+	default:
+		fmt.Printf("%s isn't on any project board and isn't referenced by any epic or theme (checked %d epics), so it will be put into triage\n", tag, len(issuesInEpics.epics))
+	}
+}
+
+// Describe every epic that mentions or links to an issue, in tag
+// order. Issues transferred since an epic mentioned them are found by
+// node ID, which means looking up what the epics mention, so that's only
+// done if nothing mentions the issue by its current name, and only for
+// mentions of issues in other repos, as that's all it could have been
+// transferred from.
+func explainEpics(ctx context.Context, client *gh.Client, issuesInEpics *epicIndex, tag, nodeID string) []string {
+	epicTags := []string{}
+	for epic, _ := range issuesInEpics.members {
+		epicTags = append(epicTags, epic)
+	}
+	sort.Strings(epicTags)
+
+	explained := []string{}
+	for _, epic := range epicTags {
+		for _, m := range issuesInEpics.members[epic] {
+			if m == tag {
+				explained = append(explained, fmt.Sprintf("%s %s: %s", issuesInEpics.kinds[epic], epic, issuesInEpics.epics[epic].GetTitle()))
+			}
+		}
+	}
+	if len(explained) > 0 {
+		return explained
+	}
+
+	repo, _, err := utils.ParseIssueTag(tag)
+	if err != nil {
+		fmt.Printf("Error parsing issue tag: %s\n", err.Error())
+		os.Exit(1)
+	}
+	_, _, inEpic := issuesInEpics.lookup(tag, nodeID)
+	if !inEpic && nodeID != "" {
+		ids := newIssueCache()
+		for m, _ := range issuesInEpics.byTag {
+			mentionedRepo, _, err := utils.ParseIssueTag(m)
+			if err != nil || mentionedRepo == repo {
+				continue
+			}
+			if ids.resolve(ctx, client, m) == nodeID {
+				issuesInEpics.byNodeID[nodeID] = m
+				break
+			}
+		}
+	}
+
+	knownAs, epic, inEpic := issuesInEpics.lookup(tag, nodeID)
+	if inEpic {
+		explained = append(explained, fmt.Sprintf("%s %s: %s, which mentions it as %s from before it was transferred", issuesInEpics.kinds[epic], epic, issuesInEpics.epics[epic].GetTitle(), knownAs))
+	}
+	return explained
+}
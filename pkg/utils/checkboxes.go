@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+)
+
+// SyncCheckboxes ticks the task list items in an epic body whose
+// issues are closed, and unticks the ones whose issues are open, going
+// by the first issue in org each item refers to. isClosed reports
+// whether an issue is closed, and whether it knows either way; items for
+// issues it doesn't know about are left alone. Nothing but the
+// checkboxes themselves is changed.
+func SyncCheckboxes(body, org, currentRepo string, isClosed func(tag string) (closed, known bool)) string {
+	newBody := []byte(body)
+
+	// Lines we have already dealt with, by where they start
+	seenLines := map[int]struct{}{}
+
+	for _, ref := range ParseIssueRefs(body, org, currentRepo) {
+		if ref.Checked == NotTask || !strings.EqualFold(ref.Owner, org) {
+			continue
+		}
+
+		lineStart := strings.LastIndexByte(body[:ref.Start], '\n') + 1
+		if _, seen := seenLines[lineStart]; seen {
+			continue
+		}
+		seenLines[lineStart] = struct{}{}
+
+		closed, known := isClosed(ref.Tag())
+		if !known || closed == (ref.Checked == Checked) {
+			continue
+		}
+
+		box := lineStart + strings.IndexByte(body[lineStart:], '[') + 1
+		if closed {
+			newBody[box] = 'x'
+		} else {
+			newBody[box] = ' '
+		}
+	}
+
+	return string(newBody)
+}
+
+// LineDiff shows the lines that differ between two versions of a text,
+// in the style of a unified diff. It compares line by line, so it only
+// makes sense for changes that don't add or remove lines, like
+// SyncCheckboxes makes.
+func LineDiff(before, after string) string {
+	beforeLines := strings.Split(before, "\n")
+	afterLines := strings.Split(after, "\n")
+
+	var out strings.Builder
+	for idx := 0; idx < len(beforeLines) || idx < len(afterLines); idx++ {
+		if idx < len(beforeLines) && idx < len(afterLines) && beforeLines[idx] == afterLines[idx] {
+			continue
+		}
+		if idx < len(beforeLines) {
+			out.WriteString("- " + beforeLines[idx] + "\n")
+		}
+		if idx < len(afterLines) {
+			out.WriteString("+ " + afterLines[idx] + "\n")
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestSyncCheckboxes(t *testing.T) {
+	body := `Here's the actions we made issues of to make this happen:
+
+- [x] https://github.com/dotmesh-io/github-issue-janitor/issues/3 - maintain the labels
+- [ ] https://github.com/dotmesh-io/github-issue-janitor/issues/6 - allow issues to exist outside a board
+  * [X] #346 - tidy up the Engineering board, after #7
+- [ ] #400 - nobody knows about this one
+- [ ] Write the docs
+[ ] #6 isn't a list item`
+
+	closed := map[string]bool{
+		"github-issue-janitor#3": false,
+		"github-issue-janitor#6": true,
+		"testrepo#346":           false,
+		"testrepo#7":             true,
+	}
+	isClosed := func(tag string) (bool, bool) {
+		c, known := closed[tag]
+		return c, known
+	}
+
+	expected := `Here's the actions we made issues of to make this happen:
+
+- [ ] https://github.com/dotmesh-io/github-issue-janitor/issues/3 - maintain the labels
+- [x] https://github.com/dotmesh-io/github-issue-janitor/issues/6 - allow issues to exist outside a board
+  * [ ] #346 - tidy up the Engineering board, after #7
+- [ ] #400 - nobody knows about this one
+- [ ] Write the docs
+[ ] #6 isn't a list item`
+
+	synced := SyncCheckboxes(body, "dotmesh-io", "testrepo", isClosed)
+	if synced != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, synced)
+	}
+
+	if SyncCheckboxes(synced, "dotmesh-io", "testrepo", isClosed) != synced {
+		t.Errorf("Syncing twice changed something the second time")
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	diff := LineDiff("one\n- [ ] two\nthree", "one\n- [x] two\nthree")
+	expected := "- - [ ] two\n+ - [x] two\n"
+	if diff != expected {
+		t.Errorf("Expected %q, got %q", expected, diff)
+	}
+
+	if LineDiff("same", "same") != "" {
+		t.Errorf("Expected no diff between identical texts")
+	}
+
+	diff = LineDiff("one", "one\ntwo")
+	if diff != "+ two\n" {
+		t.Errorf("Expected an added line, got %q", diff)
+	}
+}
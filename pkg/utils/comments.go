@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+// CommentMarker is the hidden HTML comment the janitor puts at the start
+// of its comments, so it can find them again. key says what the comment
+// is about ("stale", "rule <name>"); the janitor keeps at most one
+// comment per key on each issue.
+func CommentMarker(key string) string {
+	return "<!-- janitor:" + key + " -->"
+}
+
+// HasCommentMarker says whether a comment has the marker for key
+func HasCommentMarker(body, key string) bool {
+	return strings.Contains(body, CommentMarker(key))
+}
+
+// ExpandTemplate fills in a template (see text/template) with data
+func ExpandTemplate(name, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// RenderComment fills in a comment template with data, and marks it with
+// key.
+func RenderComment(key, text string, data interface{}) (string, error) {
+	expanded, err := ExpandTemplate(key, text, data)
+	if err != nil {
+		return "", err
+	}
+	return CommentMarker(key) + "\n" + strings.TrimRight(expanded, "\n"), nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestRenderComment(t *testing.T) {
+	data := struct {
+		Days  int
+		Label string
+	}{30, "stale"}
+
+	body, err := RenderComment("stale", "No activity for {{.Days}} days, so I've marked this as {{.Label}}.\n", data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := "<!-- janitor:stale -->\nNo activity for 30 days, so I've marked this as stale."
+	if body != expected {
+		t.Errorf("Expected %q, got %q", expected, body)
+	}
+
+	if !HasCommentMarker(body, "stale") || HasCommentMarker(body, "duplicate") {
+		t.Errorf("Markers not recognised properly in %q", body)
+	}
+
+	for _, bad := range []string{"{{.Days", "{{.Nonexistent}}"} {
+		if _, err := RenderComment("stale", bad, data); err == nil {
+			t.Errorf("Expected an error rendering %q", bad)
+		}
+	}
+	if _, err := RenderComment("x", "{{.missing}}", map[string]interface{}{}); err == nil {
+		t.Errorf("Expected an error rendering a missing map key")
+	}
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Hierarchy records which issues contain which others: themes contain
+// epics, and epics contain tasks. Issues are identified by their
+// repo#number strings. Nothing stops people making epics that contain
+// each other, so it isn't necessarily a tree.
+type Hierarchy struct {
+	// Containers (epics, themes...) -> their kind and title
+	kinds  map[string]string
+	titles map[string]string
+
+	children map[string][]string
+	parents  map[string][]string
+}
+
+func NewHierarchy() *Hierarchy {
+	return &Hierarchy{
+		kinds:    map[string]string{},
+		titles:   map[string]string{},
+		children: map[string][]string{},
+		parents:  map[string][]string{},
+	}
+}
+
+// AddContainer records an issue that contains others, such as an epic
+// or a theme.
+func (h *Hierarchy) AddContainer(tag, kind, title string) {
+	h.kinds[tag] = kind
+	h.titles[tag] = title
+}
+
+// AddChild records that parent contains child. Adding the same child
+// twice has no effect.
+func (h *Hierarchy) AddChild(parent, child string) {
+	for _, c := range h.children[parent] {
+		if c == child {
+			return
+		}
+	}
+	h.children[parent] = append(h.children[parent], child)
+	h.parents[child] = append(h.parents[child], parent)
+}
+
+// Kind returns the kind of container an issue is, or "" if it isn't one.
+func (h *Hierarchy) Kind(tag string) string {
+	return h.kinds[tag]
+}
+
+// Children returns the issues an issue contains, in the order they were
+// added.
+func (h *Hierarchy) Children(tag string) []string {
+	return h.children[tag]
+}
+
+// Parents returns the issues that contain an issue.
+func (h *Hierarchy) Parents(tag string) []string {
+	return h.parents[tag]
+}
+
+// Containers returns every container, sorted.
+func (h *Hierarchy) Containers() []string {
+	containers := []string{}
+	for tag, _ := range h.kinds {
+		containers = append(containers, tag)
+	}
+	sort.Strings(containers)
+	return containers
+}
+
+// Roots returns the containers that aren't in anything else, sorted.
+func (h *Hierarchy) Roots() []string {
+	roots := []string{}
+	for _, tag := range h.Containers() {
+		if len(h.parents[tag]) == 0 {
+			roots = append(roots, tag)
+		}
+	}
+	return roots
+}
+
+// Orphans returns the containers of the given kind that aren't in a
+// container of parentKind (eg, epics that aren't in a theme), sorted.
+func (h *Hierarchy) Orphans(kind, parentKind string) []string {
+	orphans := []string{}
+	for _, tag := range h.Containers() {
+		if h.kinds[tag] != kind {
+			continue
+		}
+		hasParent := false
+		for _, p := range h.parents[tag] {
+			if h.kinds[p] == parentKind {
+				hasParent = true
+			}
+		}
+		if !hasParent {
+			orphans = append(orphans, tag)
+		}
+	}
+	return orphans
+}
+
+// Cycles returns loops of issues that contain each other; see
+// FindCycles.
+func (h *Hierarchy) Cycles() [][]string {
+	return FindCycles(h.children)
+}
+
+// Render draws the hierarchy as an indented list, starting from the
+// roots; containers that can't be reached from a root (because they're
+// in a loop) are drawn afterwards. Plain text has two spaces of
+// indentation per level; Markdown is a nested list with every issue
+// linked to link(tag).
+func (h *Hierarchy) Render(markdown bool, link func(tag string) string) string {
+	var out strings.Builder
+	drawn := map[string]struct{}{}
+	onPath := map[string]struct{}{}
+
+	var draw func(tag string, depth int)
+	draw = func(tag string, depth int) {
+		drawn[tag] = struct{}{}
+
+		line := tag
+		if markdown {
+			line = fmt.Sprintf("[%s](%s)", tag, link(tag))
+		}
+		if kind := h.kinds[tag]; kind != "" {
+			line = fmt.Sprintf("%s %s: %s", kind, line, h.titles[tag])
+		}
+		if markdown {
+			line = "- " + line
+		}
+
+		_, looped := onPath[tag]
+		if looped {
+			line = line + " (loop!)"
+		}
+
+		out.WriteString(strings.Repeat("  ", depth))
+		out.WriteString(line)
+		out.WriteString("\n")
+
+		if looped {
+			return
+		}
+
+		onPath[tag] = struct{}{}
+		for _, child := range h.children[tag] {
+			draw(child, depth+1)
+		}
+		delete(onPath, tag)
+	}
+
+	for _, tag := range h.Roots() {
+		draw(tag, 0)
+	}
+	for _, tag := range h.Containers() {
+		if _, done := drawn[tag]; !done {
+			draw(tag, 0)
+		}
+	}
+
+	return out.String()
+}
+
+// FindCycles returns loops in a directed graph, given as a map from
+// each node to the nodes it points at. Each loop starts at its
+// alphabetically first node, and the loops are sorted. Every node that's
+// part of a loop appears in at least one of them, but when loops
+// overlap not every possible loop is listed.
+func FindCycles(edges map[string][]string) [][]string {
+	nodes := []string{}
+	for node, _ := range edges {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	path := []string{}
+	found := map[string][]string{}
+
+	var visit func(node string)
+	visit = func(node string) {
+		state[node] = visiting
+		path = append(path, node)
+
+		for _, next := range edges[node] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				// Found a way back to somewhere on our path
+				start := 0
+				for path[start] != next {
+					start++
+				}
+				cycle := rotateToSmallest(path[start:])
+				found[strings.Join(cycle, " ")] = cycle
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[node] = visited
+	}
+
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+
+	keys := []string{}
+	for key, _ := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cycles := [][]string{}
+	for _, key := range keys {
+		cycles = append(cycles, found[key])
+	}
+	return cycles
+}
+
+// Return a copy of a loop, rotated to start at its smallest element
+func rotateToSmallest(cycle []string) []string {
+	smallest := 0
+	for idx, node := range cycle {
+		if node < cycle[smallest] {
+			smallest = idx
+		}
+	}
+	rotated := append([]string{}, cycle[smallest:]...)
+	return append(rotated, cycle[:smallest]...)
+}
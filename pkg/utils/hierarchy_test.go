@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sampleHierarchy() *Hierarchy {
+	h := NewHierarchy()
+	h.AddContainer("roadmap#1", "theme", "Tame the board")
+	h.AddContainer("janitor#2", "epic", "Make the janitor smarter")
+	h.AddContainer("dotmesh#9", "epic", "Forgotten epic")
+	h.AddChild("roadmap#1", "janitor#2")
+	h.AddChild("janitor#2", "janitor#3")
+	h.AddChild("janitor#2", "janitor#6")
+	h.AddChild("janitor#2", "janitor#3")
+	h.AddChild("dotmesh#9", "dotmesh#10")
+	return h
+}
+
+func TestHierarchy(t *testing.T) {
+	h := sampleHierarchy()
+
+	if !reflect.DeepEqual(h.Children("janitor#2"), []string{"janitor#3", "janitor#6"}) {
+		t.Errorf("Expected janitor#2 to contain janitor#3 and janitor#6 once each, got %v", h.Children("janitor#2"))
+	}
+
+	if !reflect.DeepEqual(h.Parents("janitor#6"), []string{"janitor#2"}) {
+		t.Errorf("Expected janitor#6 to be in janitor#2, got %v", h.Parents("janitor#6"))
+	}
+
+	if h.Kind("janitor#2") != "epic" || h.Kind("janitor#3") != "" {
+		t.Errorf("Got the wrong kinds: %q %q", h.Kind("janitor#2"), h.Kind("janitor#3"))
+	}
+
+	expectedRoots := []string{"dotmesh#9", "roadmap#1"}
+	if !reflect.DeepEqual(h.Roots(), expectedRoots) {
+		t.Errorf("Expected roots %v, got %v", expectedRoots, h.Roots())
+	}
+
+	expectedOrphans := []string{"dotmesh#9"}
+	if !reflect.DeepEqual(h.Orphans("epic", "theme"), expectedOrphans) {
+		t.Errorf("Expected orphans %v, got %v", expectedOrphans, h.Orphans("epic", "theme"))
+	}
+
+	if len(h.Cycles()) != 0 {
+		t.Errorf("Expected no cycles, got %v", h.Cycles())
+	}
+}
+
+func TestHierarchyRender(t *testing.T) {
+	h := sampleHierarchy()
+
+	expectedText := `epic dotmesh#9: Forgotten epic
+  dotmesh#10
+theme roadmap#1: Tame the board
+  epic janitor#2: Make the janitor smarter
+    janitor#3
+    janitor#6
+`
+	text := h.Render(false, nil)
+	if text != expectedText {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedText, text)
+	}
+
+	expectedMarkdown := `- epic [dotmesh#9](url/dotmesh#9): Forgotten epic
+  - [dotmesh#10](url/dotmesh#10)
+- theme [roadmap#1](url/roadmap#1): Tame the board
+  - epic [janitor#2](url/janitor#2): Make the janitor smarter
+    - [janitor#3](url/janitor#3)
+    - [janitor#6](url/janitor#6)
+`
+	markdown := h.Render(true, func(tag string) string { return "url/" + tag })
+	if markdown != expectedMarkdown {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedMarkdown, markdown)
+	}
+}
+
+func TestHierarchyLoops(t *testing.T) {
+	h := NewHierarchy()
+	h.AddContainer("a#1", "epic", "One")
+	h.AddContainer("a#2", "epic", "Two")
+	h.AddChild("a#1", "a#2")
+	h.AddChild("a#2", "a#1")
+	h.AddChild("a#2", "a#3")
+
+	expectedCycles := [][]string{{"a#1", "a#2"}}
+	if !reflect.DeepEqual(h.Cycles(), expectedCycles) {
+		t.Errorf("Expected cycles %v, got %v", expectedCycles, h.Cycles())
+	}
+
+	// Nothing is a root, but it all still gets drawn, without going
+	// round in circles
+	expectedText := `epic a#1: One
+  epic a#2: Two
+    epic a#1: One (loop!)
+    a#3
+`
+	text := h.Render(false, nil)
+	if text != expectedText {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedText, text)
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	edges := map[string][]string{
+		"a": {"b"},
+		"b": {"c", "d"},
+		"c": {"a"},
+		"d": {"d"},
+		"e": {"a"},
+	}
+
+	expected := [][]string{{"a", "b", "c"}, {"d"}}
+	cycles := FindCycles(edges)
+	if !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Expected %v, got %v", expected, cycles)
+	}
+
+	if len(FindCycles(map[string][]string{"a": {"b"}, "b": {"c"}})) != 0 {
+		t.Errorf("Found cycles where there are none")
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "fmt"
+
+// RefFacts is what we know about the issue an epic refers to
+type RefFacts struct {
+	IgnoredRepo  bool
+	ArchivedRepo bool
+	Missing      bool
+	PullRequest  bool
+	Closed       bool
+}
+
+// LintRef describes what's wrong with an epic's reference to tag, or
+// returns "" if nothing is. Only the first problem is reported, as the
+// later ones don't matter once it's fixed.
+func LintRef(tag string, facts RefFacts) string {
+	switch {
+	case facts.IgnoredRepo:
+		return fmt.Sprintf("%s is in an ignored repo", tag)
+	case facts.ArchivedRepo:
+		return fmt.Sprintf("%s is in an archived repo", tag)
+	case facts.Missing:
+		return fmt.Sprintf("%s doesn't exist", tag)
+	case facts.PullRequest:
+		return fmt.Sprintf("%s is a pull request", tag)
+	case facts.Closed:
+		return fmt.Sprintf("%s is closed", tag)
+	}
+	return ""
+}
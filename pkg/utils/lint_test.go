@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestLintRef(t *testing.T) {
+	cases := []struct {
+		facts    RefFacts
+		expected string
+	}{
+		{RefFacts{}, ""},
+		{RefFacts{IgnoredRepo: true, Missing: true}, "dotmesh#1 is in an ignored repo"},
+		{RefFacts{ArchivedRepo: true}, "dotmesh#1 is in an archived repo"},
+		{RefFacts{Missing: true}, "dotmesh#1 doesn't exist"},
+		{RefFacts{PullRequest: true, Closed: true}, "dotmesh#1 is a pull request"},
+		{RefFacts{Closed: true}, "dotmesh#1 is closed"},
+	}
+
+	for _, c := range cases {
+		got := LintRef("dotmesh#1", c.facts)
+		if got != c.expected {
+			t.Errorf("%+v: expected %q, got %q", c.facts, c.expected, got)
+		}
+	}
+}
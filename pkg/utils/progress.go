@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markers around the part of an epic's body that the janitor keeps up
+// to date
+const ProgressBlockStart = "<!-- janitor:progress:start -->"
+const ProgressBlockEnd = "<!-- janitor:progress:end -->"
+
+// EpicProgress summarises the state of the issues in an epic. Issues
+// are identified by their repo#number strings.
+type EpicProgress struct {
+	Total  int
+	Closed int
+
+	// Open issues labelled as bugs
+	OpenBugs []string
+
+	// Open issues with nobody assigned
+	Unassigned []string
+}
+
+// Percentage of the epic's issues that are closed
+func (p EpicProgress) PercentClosed() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return p.Closed * 100 / p.Total
+}
+
+func (p EpicProgress) String() string {
+	return fmt.Sprintf("%d/%d closed (%d%%), %d open bugs, %d unassigned", p.Closed, p.Total, p.PercentClosed(), len(p.OpenBugs), len(p.Unassigned))
+}
+
+// Markdown describes the progress for putting in the epic's body, with
+// issues linked to link(tag).
+func (p EpicProgress) Markdown(link func(tag string) string) string {
+	links := func(tags []string) string {
+		ls := []string{}
+		for _, tag := range tags {
+			ls = append(ls, fmt.Sprintf("[%s](%s)", tag, link(tag)))
+		}
+		return strings.Join(ls, ", ")
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "### Progress\n\n")
+	fmt.Fprintf(&out, "**%d/%d** issues closed (%d%%)\n", p.Closed, p.Total, p.PercentClosed())
+	if len(p.OpenBugs) > 0 {
+		fmt.Fprintf(&out, "\nOpen bugs: %s\n", links(p.OpenBugs))
+	}
+	if len(p.Unassigned) > 0 {
+		fmt.Fprintf(&out, "\nNobody assigned: %s\n", links(p.Unassigned))
+	}
+	fmt.Fprintf(&out, "\n_This section is updated automatically by the issue janitor; edits to it will be lost._\n")
+	return out.String()
+}
+
+// ReplaceManagedBlock returns body with whatever is between the start
+// and end markers replaced by content. If there are no markers yet, the
+// block is added to the end of the body.
+func ReplaceManagedBlock(body, start, end, content string) string {
+	block := start + "\n" + content + end
+
+	startPos := strings.Index(body, start)
+	if startPos != -1 {
+		endPos := strings.Index(body[startPos:], end)
+		if endPos != -1 {
+			return body[:startPos] + block + body[startPos+endPos+len(end):]
+		}
+	}
+
+	if body == "" {
+		return block
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + block
+}
+
+// StripManagedBlock returns body without the block between the start
+// and end markers, so the janitor doesn't read back what it wrote.
+func StripManagedBlock(body, start, end string) string {
+	startPos := strings.Index(body, start)
+	if startPos == -1 {
+		return body
+	}
+	endPos := strings.Index(body[startPos:], end)
+	if endPos == -1 {
+		return body
+	}
+	return body[:startPos] + body[startPos+endPos+len(end):]
+}
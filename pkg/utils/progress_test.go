@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestEpicProgress(t *testing.T) {
+	p := EpicProgress{
+		Total:      4,
+		Closed:     1,
+		OpenBugs:   []string{"dotmesh#5"},
+		Unassigned: []string{"dotmesh#5", "janitor#6"},
+	}
+
+	if p.PercentClosed() != 25 {
+		t.Errorf("Expected 25%%, got %d%%", p.PercentClosed())
+	}
+
+	expected := `### Progress
+
+**1/4** issues closed (25%)
+
+Open bugs: [dotmesh#5](url/dotmesh#5)
+
+Nobody assigned: [dotmesh#5](url/dotmesh#5), [janitor#6](url/janitor#6)
+
+_This section is updated automatically by the issue janitor; edits to it will be lost._
+`
+	md := p.Markdown(func(tag string) string { return "url/" + tag })
+	if md != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, md)
+	}
+
+	if (EpicProgress{}).PercentClosed() != 0 {
+		t.Errorf("An empty epic should be 0%% done")
+	}
+}
+
+func TestReplaceManagedBlock(t *testing.T) {
+	body := "Here's the plan:\n\n- [ ] #1\n"
+
+	added := ReplaceManagedBlock(body, ProgressBlockStart, ProgressBlockEnd, "One\n")
+	expected := "Here's the plan:\n\n- [ ] #1\n\n" + ProgressBlockStart + "\nOne\n" + ProgressBlockEnd
+	if added != expected {
+		t.Errorf("Expected %q, got %q", expected, added)
+	}
+
+	// Replacing keeps whatever's around the block
+	edited := added + "\n\nMore notes"
+	replaced := ReplaceManagedBlock(edited, ProgressBlockStart, ProgressBlockEnd, "Two\n")
+	expected = "Here's the plan:\n\n- [ ] #1\n\n" + ProgressBlockStart + "\nTwo\n" + ProgressBlockEnd + "\n\nMore notes"
+	if replaced != expected {
+		t.Errorf("Expected %q, got %q", expected, replaced)
+	}
+
+	// Replacing with the same content changes nothing
+	if ReplaceManagedBlock(replaced, ProgressBlockStart, ProgressBlockEnd, "Two\n") != replaced {
+		t.Errorf("Replacing a block with the same content changed the body")
+	}
+
+	empty := ReplaceManagedBlock("", ProgressBlockStart, ProgressBlockEnd, "One\n")
+	if empty != ProgressBlockStart+"\nOne\n"+ProgressBlockEnd {
+		t.Errorf("Got %q adding a block to an empty body", empty)
+	}
+
+	stripped := StripManagedBlock(replaced, ProgressBlockStart, ProgressBlockEnd)
+	if stripped != "Here's the plan:\n\n- [ ] #1\n\n\n\nMore notes" {
+		t.Errorf("Got %q stripping the block", stripped)
+	}
+
+	if StripManagedBlock(body, ProgressBlockStart, ProgressBlockEnd) != body {
+		t.Errorf("Stripping a body with no block changed it")
+	}
+}
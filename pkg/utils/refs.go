@@ -0,0 +1,390 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RefKind says what an IssueRef points at, as far as its text tells us
+type RefKind int
+
+const (
+	// Short references like #123 can be to issues or pull requests
+	RefUnknown RefKind = iota
+	RefIssue
+	RefPullRequest
+)
+
+func (k RefKind) String() string {
+	switch k {
+	case RefIssue:
+		return "issue"
+	case RefPullRequest:
+		return "pull request"
+	default:
+		return "issue or pull request"
+	}
+}
+
+// CheckState says whether an IssueRef is in a task list item, and if
+// so, whether it's ticked
+type CheckState int
+
+const (
+	NotTask CheckState = iota
+	Unchecked
+	Checked
+)
+
+// IssueRef is a reference to an issue or pull request found in some
+// Markdown.
+type IssueRef struct {
+	Owner  string
+	Repo   string
+	Number int
+	Kind   RefKind
+
+	// Where the reference is in the text, as byte offsets: the
+	// reference is text[Start:End]
+	Start int
+	End   int
+
+	// Whether the reference is in a task list item ("- [ ] ..."), and
+	// whether that's ticked
+	Checked CheckState
+}
+
+// Tag returns the "repo#number" string we identify issues by within an
+// org.
+func (r IssueRef) Tag() string {
+	return fmt.Sprintf("%s#%d", r.Repo, r.Number)
+}
+
+func (r IssueRef) String() string {
+	return fmt.Sprintf("%s/%s#%d", r.Owner, r.Repo, r.Number)
+}
+
+// UniqueIssueRefs drops all but the first reference to each issue, as
+// epics often link to the same issue more than once ("[#4](.../issues/4)").
+// Owners and repos are compared case-insensitively, like Github does.
+func UniqueIssueRefs(refs []IssueRef) []IssueRef {
+	unique := []IssueRef{}
+	seen := map[string]struct{}{}
+	for _, ref := range refs {
+		key := strings.ToLower(ref.String())
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, ref)
+	}
+	return unique
+}
+
+var (
+	// HTML comments and tags, whose insides (eg <a href="#123">) aren't
+	// references, apart from any URLs in them. A tag name has to be followed by a space, / or >, so
+	// autolinks like <https://github.com/...> aren't taken for tags.
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTag     = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9-]*(?:[\s/][^<>]*)?>`)
+
+	// Any URL. Those that aren't links to issues are skipped over
+	// completely, so anchors like "edit#bookmark=..." or "page#123" in
+	// them aren't mistaken for short references.
+	anyURL = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `]+`)
+
+	// Links to issues and pull requests, which may have a query string
+	// or anchor on the end
+	issueURL = regexp.MustCompile(`^https?://(?:www\.)?github\.com/([A-Za-z0-9][A-Za-z0-9-]*)/([A-Za-z0-9._-]+)/(issues|pull)/([0-9]+)(?:[/?#][^\s]*)?$`)
+
+	// Task list items: "- [ ] ...", "* [x] ...", "1. [X] ..."
+	taskListPrefix = regexp.MustCompile(`^[ \t]*(?:[-*+]|[0-9]+[.)])[ \t]+\[([ xX])\][ \t]`)
+)
+
+// ParseIssueRefs finds every reference to an issue or pull request in
+// some Markdown, in the order they appear. Short references like #123
+// are taken to be to defaultOwner/defaultRepo. References inside code
+// blocks, inline code, HTML tags and comments, and URLs that aren't
+// links to issues are ignored, as Github doesn't link those either.
+// Indented code blocks aren't recognised, as they're hard to tell from
+// nested lists.
+//
+// We parse a lot of issues, so this avoids doing anything expensive
+// unless the text looks like it needs it; most of it is done by hand
+// rather than with regular expressions for the same reason.
+func ParseIssueRefs(body, defaultOwner, defaultRepo string) []IssueRef {
+	refs := []IssueRef{}
+	if strings.IndexByte(body, '#') == -1 && !strings.Contains(body, "GH-") && !strings.Contains(body, "://") {
+		return refs
+	}
+
+	masked := []byte(body)
+	mask := func(start, end int) {
+		for i := start; i < end; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+
+	if strings.IndexByte(body, '`') != -1 || strings.Contains(body, "~~~") {
+		maskCode(body, mask)
+	}
+
+	if strings.IndexByte(body, '<') != -1 {
+		for _, loc := range htmlComment.FindAllIndex(masked, -1) {
+			mask(loc[0], loc[1])
+		}
+		// Links in tags' attributes (<a href="...">) still count, so
+		// leave any URLs in them be
+		for _, loc := range htmlTag.FindAllIndex(masked, -1) {
+			start := loc[0]
+			for _, u := range anyURL.FindAllIndex(masked[loc[0]:loc[1]], -1) {
+				mask(start, loc[0]+u[0])
+				start = loc[0] + u[1]
+			}
+			mask(start, loc[1])
+		}
+	}
+
+	urlRefs := []IssueRef{}
+	if strings.Contains(body, "://") {
+		for _, loc := range anyURL.FindAllIndex(masked, -1) {
+			url := strings.TrimRight(string(masked[loc[0]:loc[1]]), ".,;:!?")
+			if m := issueURL.FindStringSubmatch(url); m != nil {
+				number, _ := strconv.Atoi(m[4])
+				kind := RefIssue
+				if m[3] == "pull" {
+					kind = RefPullRequest
+				}
+				urlRefs = append(urlRefs, IssueRef{
+					Owner:  m[1],
+					Repo:   m[2],
+					Number: number,
+					Kind:   kind,
+					Start:  loc[0],
+					End:    loc[0] + len(url),
+				})
+			}
+			mask(loc[0], loc[1])
+		}
+	}
+
+	shortRefs := findShortRefs(masked, defaultOwner, defaultRepo)
+
+	// Both lists are in order, so merge them
+	for len(urlRefs) > 0 || len(shortRefs) > 0 {
+		if len(shortRefs) == 0 || (len(urlRefs) > 0 && urlRefs[0].Start < shortRefs[0].Start) {
+			refs = append(refs, urlRefs[0])
+			urlRefs = urlRefs[1:]
+		} else {
+			refs = append(refs, shortRefs[0])
+			shortRefs = shortRefs[1:]
+		}
+	}
+
+	// Work out which references are in task list items
+	lineStart, lineEnd := -1, -1
+	state := NotTask
+	for idx := range refs {
+		if refs[idx].Start >= lineEnd {
+			lineStart = strings.LastIndexByte(body[:refs[idx].Start], '\n') + 1
+			lineEnd = strings.IndexByte(body[refs[idx].Start:], '\n')
+			if lineEnd == -1 {
+				lineEnd = len(body)
+			} else {
+				lineEnd += refs[idx].Start
+			}
+
+			state = NotTask
+			if m := taskListPrefix.FindSubmatch(masked[lineStart:lineEnd]); m != nil {
+				state = Unchecked
+				if m[1][0] != ' ' {
+					state = Checked
+				}
+			}
+		}
+		refs[idx].Checked = state
+	}
+
+	return refs
+}
+
+// Short references are #123, owner/repo#123 and GH-123. They have to
+// stand alone, so the character before them (if any) mustn't be part of
+// a word, path or entity (&#123;), and they can't run on into a word
+// afterwards.
+func findShortRefs(text []byte, defaultOwner, defaultRepo string) []IssueRef {
+	refs := []IssueRef{}
+
+	for i := 0; i < len(text); i++ {
+		ref := IssueRef{
+			Owner: defaultOwner,
+			Repo:  defaultRepo,
+			Kind:  RefUnknown,
+			Start: i,
+		}
+
+		digitsStart := 0
+		switch {
+		case text[i] == '#':
+			digitsStart = i + 1
+			if ownerStart, slash := findOwnerRepo(text, i); ownerStart != -1 {
+				ref.Start = ownerStart
+				ref.Owner = string(text[ownerStart:slash])
+				ref.Repo = string(text[slash+1 : i])
+			}
+		case text[i] == 'G' && i+3 <= len(text) && text[i+1] == 'H' && text[i+2] == '-':
+			digitsStart = i + 3
+		default:
+			continue
+		}
+
+		digitsEnd := digitsStart
+		for digitsEnd < len(text) && isDigit(text[digitsEnd]) {
+			digitsEnd++
+		}
+		if digitsEnd == digitsStart || (digitsEnd < len(text) && isWordChar(text[digitsEnd])) {
+			continue
+		}
+		if ref.Start > 0 && !canPrecedeRef(text[ref.Start-1]) {
+			continue
+		}
+
+		ref.Number, _ = strconv.Atoi(string(text[digitsStart:digitsEnd]))
+		ref.End = digitsEnd
+		refs = append(refs, ref)
+		i = digitsEnd - 1
+	}
+
+	return refs
+}
+
+// Look for an "owner/repo" just before the # at hash, returning where
+// the owner starts and where the slash is, or -1s if there isn't one.
+func findOwnerRepo(text []byte, hash int) (int, int) {
+	slash := hash
+	for slash > 0 && isRepoChar(text[slash-1]) {
+		slash--
+	}
+	if slash == hash || slash == 0 || text[slash-1] != '/' {
+		return -1, -1
+	}
+	slash--
+
+	ownerStart := slash
+	for ownerStart > 0 && (isAlphanumeric(text[ownerStart-1]) || text[ownerStart-1] == '-') {
+		ownerStart--
+	}
+	if ownerStart == slash || !isAlphanumeric(text[ownerStart]) {
+		return -1, -1
+	}
+	return ownerStart, slash
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlphanumeric(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isWordChar(c byte) bool {
+	return isAlphanumeric(c) || c == '_'
+}
+
+func isRepoChar(c byte) bool {
+	return isAlphanumeric(c) || c == '.' || c == '_' || c == '-'
+}
+
+func canPrecedeRef(c byte) bool {
+	return !isWordChar(c) && c != '/' && c != '#' && c != '&' && c != '.' && c != '-'
+}
+
+// Blank out fenced code blocks, from an opening ``` or ~~~ line to a
+// closing line with at least as many of the same character (or the end
+// of the text), and inline code spans
+func maskCode(body string, mask func(start, end int)) {
+	fence := ""
+	fenceStart := 0
+	for offset := 0; offset < len(body); {
+		lineEnd := strings.IndexByte(body[offset:], '\n')
+		if lineEnd == -1 {
+			lineEnd = len(body)
+		} else {
+			lineEnd += offset + 1
+		}
+		line := body[offset:lineEnd]
+
+		if fence == "" {
+			if f := codeFence(line); f != "" {
+				fence = f
+				fenceStart = offset
+			} else if strings.IndexByte(line, '`') != -1 {
+				maskInlineCode(line, offset, mask)
+			}
+		} else {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				mask(fenceStart, lineEnd)
+				fence = ""
+			}
+		}
+
+		offset = lineEnd
+	}
+	if fence != "" {
+		mask(fenceStart, len(body))
+	}
+}
+
+// If a line opens a fenced code block, return the fence (``` or ~~~, or
+// longer)
+func codeFence(line string) string {
+	indent := 0
+	for indent < 3 && indent < len(line) && line[indent] == ' ' {
+		indent++
+	}
+	line = line[indent:]
+	if len(line) < 3 || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	length := 0
+	for length < len(line) && line[length] == line[0] {
+		length++
+	}
+	if length < 3 {
+		return ""
+	}
+	return line[:length]
+}
+
+// Blank out inline code spans in a line, which starts at offset in the
+// whole text. A span runs from a run of backticks to the next run of
+// the same length.
+func maskInlineCode(line string, offset int, mask func(start, end int)) {
+	runs := [][2]int{}
+	for i := 0; i < len(line); i++ {
+		if line[i] == '`' {
+			start := i
+			for i < len(line) && line[i] == '`' {
+				i++
+			}
+			runs = append(runs, [2]int{start, i})
+		}
+	}
+
+	for i := 0; i < len(runs); i++ {
+		length := runs[i][1] - runs[i][0]
+		for j := i + 1; j < len(runs); j++ {
+			if runs[j][1]-runs[j][0] == length {
+				mask(offset+runs[i][0], offset+runs[j][1])
+				i = j
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Describe refs compactly, for comparing with expected results
+func describeRefs(body string, refs []IssueRef) string {
+	descriptions := []string{}
+	for _, r := range refs {
+		d := fmt.Sprintf("%s (%s) %q", r.String(), r.Kind, body[r.Start:r.End])
+		switch r.Checked {
+		case Checked:
+			d = d + " [x]"
+		case Unchecked:
+			d = d + " [ ]"
+		}
+		descriptions = append(descriptions, d)
+	}
+	return strings.Join(descriptions, "\n")
+}
+
+func TestParseIssueRefs(t *testing.T) {
+	corpus := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name: "short reference",
+			body: "See #12 for details",
+			expected: []string{
+				`dotmesh-io/dotmesh#12 (issue or pull request) "#12"`,
+			},
+		},
+		{
+			name: "short references with punctuation around them",
+			body: "(#1), #2. #3, [#4]\n#5: #6!",
+			expected: []string{
+				`dotmesh-io/dotmesh#1 (issue or pull request) "#1"`,
+				`dotmesh-io/dotmesh#2 (issue or pull request) "#2"`,
+				`dotmesh-io/dotmesh#3 (issue or pull request) "#3"`,
+				`dotmesh-io/dotmesh#4 (issue or pull request) "#4"`,
+				`dotmesh-io/dotmesh#5 (issue or pull request) "#5"`,
+				`dotmesh-io/dotmesh#6 (issue or pull request) "#6"`,
+			},
+		},
+		{
+			name: "owner/repo references",
+			body: "Blocked on kubernetes/kubernetes#1234 and dotmesh-io/github-issue-janitor#7",
+			expected: []string{
+				`kubernetes/kubernetes#1234 (issue or pull request) "kubernetes/kubernetes#1234"`,
+				`dotmesh-io/github-issue-janitor#7 (issue or pull request) "dotmesh-io/github-issue-janitor#7"`,
+			},
+		},
+		{
+			name: "GH- references",
+			body: "Fixed by GH-42, not XGH-43",
+			expected: []string{
+				`dotmesh-io/dotmesh#42 (issue or pull request) "GH-42"`,
+			},
+		},
+		{
+			name: "issue and pull request URLs",
+			body: "https://github.com/dotmesh-io/dotmesh/issues/3 and https://github.com/moby/moby/pull/99",
+			expected: []string{
+				`dotmesh-io/dotmesh#3 (issue) "https://github.com/dotmesh-io/dotmesh/issues/3"`,
+				`moby/moby#99 (pull request) "https://github.com/moby/moby/pull/99"`,
+			},
+		},
+		{
+			name: "URLs with query strings, anchors and trailing punctuation",
+			body: "https://github.com/dotmesh-io/dotmesh/issues/3#issuecomment-123456, https://github.com/dotmesh-io/dotmesh/pull/4/files?w=1.",
+			expected: []string{
+				`dotmesh-io/dotmesh#3 (issue) "https://github.com/dotmesh-io/dotmesh/issues/3#issuecomment-123456"`,
+				`dotmesh-io/dotmesh#4 (pull request) "https://github.com/dotmesh-io/dotmesh/pull/4/files?w=1"`,
+			},
+		},
+		{
+			name: "Markdown links",
+			body: "[the bug](https://github.com/dotmesh-io/dotmesh/issues/5)",
+			expected: []string{
+				`dotmesh-io/dotmesh#5 (issue) "https://github.com/dotmesh-io/dotmesh/issues/5"`,
+			},
+		},
+		{
+			name:     "anchors in other URLs",
+			body:     "https://docs.google.com/document/d/1XUJ/edit#bookmark=id.ggjx2k8m2k4x and http://example.com/page#123 and https://github.com/dotmesh-io/dotmesh/wiki#12",
+			expected: []string{},
+		},
+		{
+			name:     "things that look a bit like references",
+			body:     "issue#1, a/b/c#2, &#35;, &#123;, #12abc, ##, 1#2, C# 3",
+			expected: []string{},
+		},
+		{
+			name:     "inline code",
+			body:     "Run `git log #1` or ``echo `#2` ``",
+			expected: []string{},
+		},
+		{
+			name: "fenced code blocks",
+			body: "Before #1\n```\n#2\n```\n~~~~ shell\n#3\n~~~\n#4\n~~~~\nAfter #5",
+			expected: []string{
+				`dotmesh-io/dotmesh#1 (issue or pull request) "#1"`,
+				`dotmesh-io/dotmesh#5 (issue or pull request) "#5"`,
+			},
+		},
+		{
+			name:     "unterminated code block",
+			body:     "```\n#1\n",
+			expected: []string{},
+		},
+		{
+			name: "HTML",
+			body: `<a href="#123">#7</a> <a name="bookmark=1"></a><!-- #8 -->`,
+			expected: []string{
+				`dotmesh-io/dotmesh#7 (issue or pull request) "#7"`,
+			},
+		},
+		{
+			name: "HTML links",
+			body: `<a href="https://github.com/dotmesh-io/x/issues/5" title="#6">the fix</a> <a href='https://example.com/page#7'>#8</a>`,
+			expected: []string{
+				`dotmesh-io/x#5 (issue) "https://github.com/dotmesh-io/x/issues/5"`,
+				`dotmesh-io/dotmesh#8 (issue or pull request) "#8"`,
+			},
+		},
+		{
+			name: "autolinks",
+			body: "See <https://github.com/dotmesh-io/dotmesh/issues/12> and <br/><b>#13</b>",
+			expected: []string{
+				`dotmesh-io/dotmesh#12 (issue) "https://github.com/dotmesh-io/dotmesh/issues/12"`,
+				`dotmesh-io/dotmesh#13 (issue or pull request) "#13"`,
+			},
+		},
+		{
+			name: "task lists",
+			body: "- [x] #1 done\n- [ ] #2 and #3\n  * [X] nested #4\n1. [ ] numbered #5\n#6 - [ ] not a task",
+			expected: []string{
+				`dotmesh-io/dotmesh#1 (issue or pull request) "#1" [x]`,
+				`dotmesh-io/dotmesh#2 (issue or pull request) "#2" [ ]`,
+				`dotmesh-io/dotmesh#3 (issue or pull request) "#3" [ ]`,
+				`dotmesh-io/dotmesh#4 (issue or pull request) "#4" [x]`,
+				`dotmesh-io/dotmesh#5 (issue or pull request) "#5" [ ]`,
+				`dotmesh-io/dotmesh#6 (issue or pull request) "#6"`,
+			},
+		},
+		{
+			name: "Windows line endings",
+			body: "- [x] #1\r\n- [ ] https://github.com/dotmesh-io/dotmesh/issues/2\r\n",
+			expected: []string{
+				`dotmesh-io/dotmesh#1 (issue or pull request) "#1" [x]`,
+				`dotmesh-io/dotmesh#2 (issue) "https://github.com/dotmesh-io/dotmesh/issues/2" [ ]`,
+			},
+		},
+		{
+			name:     "empty",
+			body:     "",
+			expected: []string{},
+		},
+	}
+
+	for _, c := range corpus {
+		refs := ParseIssueRefs(c.body, "dotmesh-io", "dotmesh")
+		got := describeRefs(c.body, refs)
+		expected := strings.Join(c.expected, "\n")
+		if got != expected {
+			t.Errorf("%s: parsing %q\nExpected:\n%s\nGot:\n%s", c.name, c.body, expected, got)
+		}
+	}
+}
+
+func TestIssueRefTag(t *testing.T) {
+	refs := ParseIssueRefs("moby/moby#99", "dotmesh-io", "dotmesh")
+	if len(refs) != 1 || refs[0].Tag() != "moby#99" || refs[0].String() != "moby/moby#99" {
+		t.Errorf("Got %#v", refs)
+	}
+}
+
+func TestUniqueIssueRefs(t *testing.T) {
+	body := "- [ ] [#4](https://github.com/dotmesh-io/dotmesh/issues/4)\n- [ ] #2, Dotmesh-IO/Dotmesh#4, moby/moby#2\n- [x] GH-2"
+	got := describeRefs(body, UniqueIssueRefs(ParseIssueRefs(body, "dotmesh-io", "dotmesh")))
+	expected := strings.Join([]string{
+		`dotmesh-io/dotmesh#4 (issue or pull request) "#4" [ ]`,
+		`dotmesh-io/dotmesh#2 (issue or pull request) "#2" [ ]`,
+		`moby/moby#2 (issue or pull request) "moby/moby#2" [ ]`,
+	}, "\n")
+	if got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+// A typical epic, with a bit of everything in it
+var benchmarkBody = strings.Repeat(`As discussed in the Engineering Banner meeting, we want to tame the board: https://docs.google.com/document/d/1XUJRJ1upx3vn0jyu1JNQL-6lfgp4YMC_fZZ_tPJfVHA/edit#bookmark=id.ggjx2k8m2k4x
+
+- [x] https://github.com/dotmesh-io/github-issue-janitor/issues/3 - make the janitor maintain the labels in every repo
+- [ ] https://github.com/dotmesh-io/github-issue-janitor/issues/6 - make the janitor allow issues to exist "outside" of a board
+- [ ] #346 - with that groundwork in place, we tidy up the Engineering board, see also #7 and moby/moby#99.
+
+`+"```"+`
+$ dm clone #notanissue
+`+"```"+`
+`, 10)
+
+// A typical issue, with no references at all
+var benchmarkPlainBody = strings.Repeat("When I run dm push, it hangs for a minute and then says the remote isn't there. It was fine yesterday.\n\n", 10)
+
+func BenchmarkParseIssueRefs(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseIssueRefs(benchmarkBody, "dotmesh-io", "dotmesh")
+	}
+}
+
+func BenchmarkParseIssueRefsPlain(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseIssueRefs(benchmarkPlainBody, "dotmesh-io", "dotmesh")
+	}
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RelationKind says how an issue relates to one it refers to
+type RelationKind int
+
+const (
+	// "Fixes #1", "closes #1", "resolves #1" and so on, which Github
+	// acts on when a pull request is merged
+	RelFixes RelationKind = iota
+	RelBlockedBy
+	RelDependsOn
+	RelDuplicateOf
+)
+
+func (k RelationKind) String() string {
+	switch k {
+	case RelFixes:
+		return "fixes"
+	case RelBlockedBy:
+		return "blocked by"
+	case RelDependsOn:
+		return "depends on"
+	case RelDuplicateOf:
+		return "duplicate of"
+	default:
+		return "unknown"
+	}
+}
+
+// Blocks says whether the issue with the relation can't be finished
+// until the one it refers to is
+func (k RelationKind) Blocks() bool {
+	return k == RelBlockedBy || k == RelDependsOn
+}
+
+// IssueRelation is a reference to an issue together with what the text
+// says about it
+type IssueRelation struct {
+	Kind RelationKind
+	Ref  IssueRef
+}
+
+func (r IssueRelation) String() string {
+	return r.Kind.String() + " " + r.Ref.String()
+}
+
+var (
+	// Phrases that come just before a reference to say what it is, with
+	// any punctuation or Markdown between them and the reference:
+	// "Fixes: #1", "blocked by **#2**", "duplicate of [#3](...)"
+	relationKeyword = regexp.MustCompile(`(?i)\b(close[sd]?|fix(?:e[sd])?|resolve[sd]?|blocked (?:by|on)|depends? on|dependent on|duplicate of|dupe of)[ \t]*:?[ \t*_(\[]*$`)
+
+	// What can separate references in a list that a phrase applies to:
+	// "blocked by #1, #2 and #3". Markdown links' brackets count, as
+	// "[#4](https://.../issues/4)" is two references to the same issue.
+	relationSeparator = regexp.MustCompile(`(?i)^[ \t*_()\[\]]*(?:(?:,|&|and|or)[ \t*_()\[\]]*)*$`)
+)
+
+// ParseIssueRelations finds the references in some Markdown that say
+// something about the issue they refer to ("fixes #1", "blocked by #2",
+// "depends on #3", "duplicate of #4"), in the order they first appear.
+// A phrase covers a list of references after it on the same line, such
+// as "depends on #1, #2 and #3". Other references are left out; see
+// ParseIssueRefs for which references are found and what short
+// references are taken to be to.
+func ParseIssueRelations(body, defaultOwner, defaultRepo string) []IssueRelation {
+	relations := []IssueRelation{}
+	seen := map[string]struct{}{}
+
+	refs := ParseIssueRefs(body, defaultOwner, defaultRepo)
+
+	// What each reference was found to be, if anything
+	kinds := make([]RelationKind, len(refs))
+	related := make([]bool, len(refs))
+
+	for idx, ref := range refs {
+		lineStart := strings.LastIndexByte(body[:ref.Start], '\n') + 1
+
+		// Only look at the text since the last reference on this line,
+		// so "fixes #1, see #2" doesn't say anything about #2
+		textStart := lineStart
+		previous := -1
+		if idx > 0 && refs[idx-1].End > lineStart {
+			textStart = refs[idx-1].End
+			previous = idx - 1
+		}
+		text := body[textStart:ref.Start]
+
+		var kind RelationKind
+		if m := relationKeyword.FindStringSubmatch(text); m != nil {
+			kind = relationKind(m[1])
+		} else if previous != -1 && related[previous] && relationSeparator.MatchString(text) {
+			kind = kinds[previous]
+		} else {
+			continue
+		}
+		kinds[idx] = kind
+		related[idx] = true
+
+		key := kind.String() + " " + strings.ToLower(ref.String())
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		relations = append(relations, IssueRelation{Kind: kind, Ref: ref})
+	}
+
+	return relations
+}
+
+func relationKind(keyword string) RelationKind {
+	keyword = strings.ToLower(keyword)
+	switch {
+	case strings.HasPrefix(keyword, "blocked"):
+		return RelBlockedBy
+	case strings.HasPrefix(keyword, "depend"):
+		return RelDependsOn
+	case strings.HasPrefix(keyword, "dup"):
+		return RelDuplicateOf
+	default:
+		return RelFixes
+	}
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIssueRelations(t *testing.T) {
+	corpus := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name:     "close keywords",
+			body:     "Fixes #1. This closes #2; resolved GH-3, fixed: dotmesh-io/janitor#4",
+			expected: []string{"fixes dotmesh-io/dotmesh#1", "fixes dotmesh-io/dotmesh#2", "fixes dotmesh-io/dotmesh#3", "fixes dotmesh-io/janitor#4"},
+		},
+		{
+			name:     "dependencies",
+			body:     "Blocked by #1\nThis depends on https://github.com/moby/moby/issues/9\nBlocked on **#2**",
+			expected: []string{"blocked by dotmesh-io/dotmesh#1", "depends on moby/moby#9", "blocked by dotmesh-io/dotmesh#2"},
+		},
+		{
+			name:     "duplicates",
+			body:     "Duplicate of [#4](https://github.com/dotmesh-io/dotmesh/issues/4)",
+			expected: []string{"duplicate of dotmesh-io/dotmesh#4"},
+		},
+		{
+			name:     "lists",
+			body:     "Depends on #1, #2 and #3, & #4\nsee #5",
+			expected: []string{"depends on dotmesh-io/dotmesh#1", "depends on dotmesh-io/dotmesh#2", "depends on dotmesh-io/dotmesh#3", "depends on dotmesh-io/dotmesh#4"},
+		},
+		{
+			name:     "phrases only cover what follows them directly",
+			body:     "Fixes #1, see also #2\nBlocked by\n#3\nNot blocked by anything, #4",
+			expected: []string{"fixes dotmesh-io/dotmesh#1"},
+		},
+		{
+			name:     "plain references and code",
+			body:     "Like #1 and #2. `fixes #3`\n```\nblocked by #4\n```",
+			expected: []string{},
+		},
+		{
+			name:     "prefixes of words",
+			body:     "prefixes #1 undepends on #2",
+			expected: []string{},
+		},
+	}
+
+	for _, c := range corpus {
+		got := []string{}
+		for _, r := range ParseIssueRelations(c.body, "dotmesh-io", "dotmesh") {
+			got = append(got, r.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(c.expected, "\n") {
+			t.Errorf("%s: parsing %q\nExpected:\n%s\nGot:\n%s", c.name, c.body, strings.Join(c.expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
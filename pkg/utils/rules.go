@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Age is a length of time in a rules file: "30d", or anything
+// time.ParseDuration understands, like "12h".
+type Age time.Duration
+
+func ParseAge(s string) (Age, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return Age(time.Duration(days) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return Age(d), nil
+}
+
+func (a *Age) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("ages must be strings like \"30d\": %s", err.Error())
+	}
+	age, err := ParseAge(s)
+	if err != nil {
+		return err
+	}
+	*a = age
+	return nil
+}
+
+// RuleConditions are what an issue has to be like for a rule to apply
+// to it. Conditions that are left out always hold; all the others must.
+type RuleConditions struct {
+	// The issue is in one of these repos
+	Repos []string `json:"repos,omitempty"`
+
+	// The issue has all of these labels, and none of the NotLabels
+	Labels    []string `json:"labels,omitempty"`
+	NotLabels []string `json:"not_labels,omitempty"`
+
+	// "open" or "closed"
+	State string `json:"state,omitempty"`
+
+	// Since the issue was opened, and since it last changed
+	OlderThan   Age `json:"older_than,omitempty"`
+	InactiveFor Age `json:"inactive_for,omitempty"`
+
+	// Whether anybody is assigned, and if Assignees is given, whether
+	// any of them are
+	Assigned  *bool    `json:"assigned,omitempty"`
+	Assignees []string `json:"assignees,omitempty"`
+
+	// Whether it has a card on a project board, and whether an epic
+	// (or theme) mentions it
+	InProject *bool `json:"in_project,omitempty"`
+	InEpic    *bool `json:"in_epic,omitempty"`
+
+	PullRequest *bool `json:"pull_request,omitempty"`
+}
+
+// Things a rule can do
+const (
+	ActionAddLabel    = "add_label"
+	ActionRemoveLabel = "remove_label"
+	ActionComment     = "comment"
+	ActionMoveCard    = "move_card"
+	ActionClose       = "close"
+	ActionAssign      = "assign"
+)
+
+// RuleAction is something a rule does to the issues it applies to. Which
+// of the other fields are needed depends on Action.
+type RuleAction struct {
+	Action string `json:"action"`
+
+	// For add_label and remove_label
+	Label string `json:"label,omitempty"`
+
+	// For comment. Each rule only ever comments on an issue once.
+	Body string `json:"body,omitempty"`
+
+	// For move_card: the ID of the column to move the issue's card to,
+	// on the same board. Issues without a card on that board get one.
+	Column int64 `json:"column,omitempty"`
+
+	// For assign
+	Users []string `json:"users,omitempty"`
+}
+
+func (a RuleAction) String() string {
+	switch a.Action {
+	case ActionAddLabel, ActionRemoveLabel:
+		return a.Action + " " + a.Label
+	case ActionMoveCard:
+		return fmt.Sprintf("%s to column %d", a.Action, a.Column)
+	case ActionAssign:
+		return a.Action + " " + strings.Join(a.Users, ", ")
+	default:
+		return a.Action
+	}
+}
+
+// Validate checks an action is one we know, with what it needs
+func (a RuleAction) Validate() error {
+	var missing string
+	switch a.Action {
+	case ActionAddLabel, ActionRemoveLabel:
+		if a.Label == "" {
+			missing = "label"
+		}
+	case ActionComment:
+		if a.Body == "" {
+			missing = "body"
+		}
+	case ActionMoveCard:
+		if a.Column == 0 {
+			missing = "column"
+		}
+	case ActionAssign:
+		if len(a.Users) == 0 {
+			missing = "users"
+		}
+	case ActionClose:
+	default:
+		return fmt.Errorf("unknown action %q", a.Action)
+	}
+	if missing != "" {
+		return fmt.Errorf("%s needs a %s", a.Action, missing)
+	}
+	return nil
+}
+
+// Rule is a named set of conditions, and what to do to the issues that
+// meet them.
+type Rule struct {
+	Name string         `json:"name"`
+	If   RuleConditions `json:"if"`
+	Then []RuleAction   `json:"then"`
+}
+
+// ParseRules reads rules from JSON (a list of Rule objects), and checks
+// they make sense.
+func ParseRules(data []byte) ([]Rule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	rules := []Rule{}
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("error parsing rules: %s", err.Error())
+	}
+
+	names := map[string]struct{}{}
+	for idx, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", idx+1)
+		}
+		if _, dup := names[rule.Name]; dup {
+			return nil, fmt.Errorf("there's more than one rule called %q", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		if s := rule.If.State; s != "" && s != "open" && s != "closed" {
+			return nil, fmt.Errorf("rule %q: state must be open or closed, not %q", rule.Name, s)
+		}
+		if len(rule.Then) == 0 {
+			return nil, fmt.Errorf("rule %q doesn't do anything", rule.Name)
+		}
+
+		for _, action := range rule.Then {
+			if err := action.Validate(); err != nil {
+				return nil, fmt.Errorf("rule %q: %s", rule.Name, err.Error())
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// IssueFacts is what rules know about an issue
+type IssueFacts struct {
+	Repo        string
+	Labels      []string
+	State       string
+	Created     time.Time
+	Updated     time.Time
+	Assignees   []string
+	InProject   bool
+	InEpic      bool
+	PullRequest bool
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Match says whether an issue meets all the conditions
+func (c RuleConditions) Match(issue IssueFacts, now time.Time) bool {
+	if len(c.Repos) > 0 && !contains(c.Repos, issue.Repo) {
+		return false
+	}
+	for _, l := range c.Labels {
+		if !contains(issue.Labels, l) {
+			return false
+		}
+	}
+	for _, l := range c.NotLabels {
+		if contains(issue.Labels, l) {
+			return false
+		}
+	}
+	if c.State != "" && c.State != issue.State {
+		return false
+	}
+	if c.OlderThan > 0 && now.Sub(issue.Created) < time.Duration(c.OlderThan) {
+		return false
+	}
+	if c.InactiveFor > 0 && now.Sub(issue.Updated) < time.Duration(c.InactiveFor) {
+		return false
+	}
+	if c.Assigned != nil && *c.Assigned != (len(issue.Assignees) > 0) {
+		return false
+	}
+	if len(c.Assignees) > 0 {
+		found := false
+		for _, a := range c.Assignees {
+			if contains(issue.Assignees, a) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.InProject != nil && *c.InProject != issue.InProject {
+		return false
+	}
+	if c.InEpic != nil && *c.InEpic != issue.InEpic {
+		return false
+	}
+	if c.PullRequest != nil && *c.PullRequest != issue.PullRequest {
+		return false
+	}
+	return true
+}
+
+// Plan works out what a rule would do to an issue: nothing if it
+// doesn't meet the conditions, otherwise PlanActions of its actions.
+func (r Rule) Plan(issue IssueFacts, now time.Time) []RuleAction {
+	if !r.If.Match(issue, now) {
+		return []RuleAction{}
+	}
+	return PlanActions(r.Then, issue)
+}
+
+// PlanActions returns the actions that would change something about an
+// issue. Actions that need to look at Github to tell (comments and card
+// moves) are always included.
+func PlanActions(actions []RuleAction, issue IssueFacts) []RuleAction {
+	planned := []RuleAction{}
+	for _, action := range actions {
+		switch action.Action {
+		case ActionAddLabel:
+			if contains(issue.Labels, action.Label) {
+				continue
+			}
+		case ActionRemoveLabel:
+			if !contains(issue.Labels, action.Label) {
+				continue
+			}
+		case ActionClose:
+			if issue.State == "closed" {
+				continue
+			}
+		case ActionAssign:
+			users := []string{}
+			for _, u := range action.Users {
+				if !contains(issue.Assignees, u) {
+					users = append(users, u)
+				}
+			}
+			if len(users) == 0 {
+				continue
+			}
+			action.Users = users
+		}
+		planned = append(planned, action)
+	}
+	return planned
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleRules = `[
+	{
+		"name": "urgent and forgotten",
+		"if": {
+			"labels": ["urgency:high"],
+			"not_labels": ["epic"],
+			"inactive_for": "14d",
+			"assigned": false,
+			"pull_request": false
+		},
+		"then": [
+			{"action": "add_label", "label": "triage-overdue"},
+			{"action": "comment", "body": "This is urgent, but nobody's looking at it!"},
+			{"action": "move_card", "column": 1527643},
+			{"action": "assign", "users": ["alice", "bob"]}
+		]
+	},
+	{
+		"name": "lost in dotmesh",
+		"if": {"repos": ["dotmesh"], "in_project": false, "in_epic": false, "older_than": "12h"},
+		"then": [{"action": "close"}, {"action": "remove_label", "label": "bug"}]
+	}
+]`
+
+func describePlan(actions []RuleAction) string {
+	descriptions := []string{}
+	for _, a := range actions {
+		descriptions = append(descriptions, a.String())
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+func TestRules(t *testing.T) {
+	rules, err := ParseRules([]byte(sampleRules))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	now := time.Date(2018, 6, 30, 12, 0, 0, 0, time.UTC)
+	issue := IssueFacts{
+		Repo:      "dotmesh",
+		Labels:    []string{"bug", "urgency:high"},
+		State:     "open",
+		Created:   now.Add(-30 * 24 * time.Hour),
+		Updated:   now.Add(-20 * 24 * time.Hour),
+		InProject: true,
+	}
+
+	corpus := []struct {
+		name     string
+		rule     int
+		change   func(i *IssueFacts)
+		expected string
+	}{
+		{"matches", 0, func(i *IssueFacts) {}, "add_label triage-overdue; comment; move_card to column 1527643; assign alice, bob"},
+		{"already done some of it", 0, func(i *IssueFacts) {
+			i.Labels = append(i.Labels, "triage-overdue")
+		}, "comment; move_card to column 1527643; assign alice, bob"},
+		{"too recent", 0, func(i *IssueFacts) { i.Updated = now }, ""},
+		{"assigned", 0, func(i *IssueFacts) { i.Assignees = []string{"bob"} }, ""},
+		{"excluded label", 0, func(i *IssueFacts) { i.Labels = append(i.Labels, "epic") }, ""},
+		{"missing label", 0, func(i *IssueFacts) { i.Labels = []string{"bug"} }, ""},
+		{"pull request", 0, func(i *IssueFacts) { i.PullRequest = true }, ""},
+		{"in a project", 1, func(i *IssueFacts) {}, ""},
+		{"lost", 1, func(i *IssueFacts) { i.InProject = false }, "close; remove_label bug"},
+		{"other repo", 1, func(i *IssueFacts) { i.InProject = false; i.Repo = "janitor" }, ""},
+		{"in an epic", 1, func(i *IssueFacts) { i.InProject = false; i.InEpic = true }, ""},
+		{"closed", 1, func(i *IssueFacts) { i.InProject = false; i.State = "closed" }, "remove_label bug"},
+	}
+
+	for _, c := range corpus {
+		facts := issue
+		facts.Labels = append([]string{}, issue.Labels...)
+		c.change(&facts)
+		got := describePlan(rules[c.rule].Plan(facts, now))
+		if got != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"name": "not a list"}`,
+		`[{"if": {}, "then": [{"action": "close"}]}]`,
+		`[{"name": "a", "then": [{"action": "close"}]}, {"name": "a", "then": [{"action": "close"}]}]`,
+		`[{"name": "a", "then": []}]`,
+		`[{"name": "a", "then": [{"action": "explode"}]}]`,
+		`[{"name": "a", "then": [{"action": "add_label"}]}]`,
+		`[{"name": "a", "if": {"state": "lost"}, "then": [{"action": "close"}]}]`,
+		`[{"name": "a", "if": {"older_than": "a while"}, "then": [{"action": "close"}]}]`,
+		`[{"name": "a", "if": {"colour": "red"}, "then": [{"action": "close"}]}]`,
+	} {
+		if _, err := ParseRules([]byte(bad)); err == nil {
+			t.Errorf("Expected an error parsing %s", bad)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"0d":  0,
+	} {
+		got, err := ParseAge(s)
+		if err != nil || time.Duration(got) != expected {
+			t.Errorf("Parsing %q: expected %s, got %s (%v)", s, expected, time.Duration(got), err)
+		}
+	}
+	for _, bad := range []string{"", "d", "-1d", "soon"} {
+		if _, err := ParseAge(bad); err == nil {
+			t.Errorf("Expected an error parsing %q", bad)
+		}
+	}
+}
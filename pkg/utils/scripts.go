@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// ScriptIssue is what scripts are told about an issue: everything rules
+// can look at, and a bit more.
+type ScriptIssue struct {
+	IssueFacts
+	Number int
+	Title  string
+	Body   string
+	Author string
+	URL    string
+}
+
+// Script is a policy written in Starlark (a small, Python-like language
+// that can't do anything but compute: see
+// https://github.com/google/starlark-go). Scripts define a function
+//
+//	def check(issue, repo):
+//
+// that returns a list of actions to take on an issue (or None), made with
+// the built-in functions add_label(label), remove_label(label),
+// comment(body), move_card(column), close() and assign(user, ...).
+// issue has the fields repo, number, tag, title, body, author, url,
+// labels, assignees, state, age_days, inactive_days, in_project,
+// in_epic and pull_request; repo has name and org.
+//
+// Scripts can't read files, use the network or load other scripts, and
+// Starlark has no unbounded loops or recursion, but a script can still
+// loop for a very long time. Check stops waiting for it after Timeout
+// and returns an error, but this version of Starlark has no way to stop
+// a running script, so it carries on in the background: the caller
+// should treat that error as fatal.
+type Script struct {
+	Name    string
+	Timeout time.Duration
+
+	check starlark.Value
+}
+
+// Built-in functions for making actions
+var scriptBuiltins = starlark.StringDict{
+	"add_label":    starlark.NewBuiltin("add_label", labelBuiltin(ActionAddLabel)),
+	"remove_label": starlark.NewBuiltin("remove_label", labelBuiltin(ActionRemoveLabel)),
+	"comment": starlark.NewBuiltin("comment", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var body string
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &body); err != nil {
+			return nil, err
+		}
+		return scriptAction{RuleAction{Action: ActionComment, Body: body}}, nil
+	}),
+	"move_card": starlark.NewBuiltin("move_card", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var column starlark.Int
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &column); err != nil {
+			return nil, err
+		}
+		id, ok := column.Int64()
+		if !ok {
+			return nil, fmt.Errorf("move_card: column %s is out of range", column.String())
+		}
+		return scriptAction{RuleAction{Action: ActionMoveCard, Column: id}}, nil
+	}),
+	"close": starlark.NewBuiltin("close", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		return scriptAction{RuleAction{Action: ActionClose}}, nil
+	}),
+	"assign": starlark.NewBuiltin("assign", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if len(kwargs) > 0 {
+			return nil, fmt.Errorf("assign: unexpected keyword arguments")
+		}
+		users := []string{}
+		for _, arg := range args {
+			user, ok := starlark.AsString(arg)
+			if !ok {
+				return nil, fmt.Errorf("assign: got %s, want string", arg.Type())
+			}
+			users = append(users, user)
+		}
+		return scriptAction{RuleAction{Action: ActionAssign, Users: users}}, nil
+	}),
+}
+
+func labelBuiltin(action string) func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var label string
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &label); err != nil {
+			return nil, err
+		}
+		return scriptAction{RuleAction{Action: action, Label: label}}, nil
+	}
+}
+
+// scriptAction is a RuleAction as a Starlark value
+type scriptAction struct {
+	action RuleAction
+}
+
+func (a scriptAction) String() string        { return "<action " + a.action.String() + ">" }
+func (a scriptAction) Type() string          { return "action" }
+func (a scriptAction) Freeze()               {}
+func (a scriptAction) Truth() starlark.Bool  { return starlark.True }
+func (a scriptAction) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: action") }
+
+func newScriptThread(name string) *starlark.Thread {
+	return &starlark.Thread{
+		Name: name,
+		Print: func(thread *starlark.Thread, msg string) {
+			fmt.Printf("[%s] %s\n", name, msg)
+		},
+		Load: func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("scripts can't load other scripts")
+		},
+	}
+}
+
+// LoadScript runs a script's top level, and checks it defines check().
+func LoadScript(name string, src []byte, timeout time.Duration) (*Script, error) {
+	globals, err := starlark.ExecFile(newScriptThread(name), name, src, scriptBuiltins)
+	if err != nil {
+		return nil, err
+	}
+
+	check, ok := globals["check"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't define check(issue, repo)", name)
+	}
+	return &Script{Name: name, Timeout: timeout, check: check}, nil
+}
+
+func stringTuple(strings []string) starlark.Tuple {
+	sorted := append([]string{}, strings...)
+	sort.Strings(sorted)
+	tuple := starlark.Tuple{}
+	for _, s := range sorted {
+		tuple = append(tuple, starlark.String(s))
+	}
+	return tuple
+}
+
+// Check asks a script what to do about an issue, in org. Only the
+// actions that would change something are returned; see PlanActions.
+func (s *Script) Check(org string, issue ScriptIssue, now time.Time) ([]RuleAction, error) {
+	days := func(since time.Time) starlark.Int {
+		return starlark.MakeInt(int(now.Sub(since).Hours() / 24))
+	}
+
+	issueValue := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"repo":          starlark.String(issue.Repo),
+		"number":        starlark.MakeInt(issue.Number),
+		"tag":           starlark.String(fmt.Sprintf("%s#%d", issue.Repo, issue.Number)),
+		"title":         starlark.String(issue.Title),
+		"body":          starlark.String(issue.Body),
+		"author":        starlark.String(issue.Author),
+		"url":           starlark.String(issue.URL),
+		"labels":        stringTuple(issue.Labels),
+		"assignees":     stringTuple(issue.Assignees),
+		"state":         starlark.String(issue.State),
+		"age_days":      days(issue.Created),
+		"inactive_days": days(issue.Updated),
+		"in_project":    starlark.Bool(issue.InProject),
+		"in_epic":       starlark.Bool(issue.InEpic),
+		"pull_request":  starlark.Bool(issue.PullRequest),
+	})
+	repoValue := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"name": starlark.String(issue.Repo),
+		"org":  starlark.String(org),
+	})
+
+	type result struct {
+		value starlark.Value
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := starlark.Call(newScriptThread(s.Name), s.check, starlark.Tuple{issueValue, repoValue}, nil)
+		done <- result{value, err}
+	}()
+
+	var r result
+	if s.Timeout > 0 {
+		select {
+		case r = <-done:
+		case <-time.After(s.Timeout):
+			return nil, fmt.Errorf("%s took more than %s", s.Name, s.Timeout.String())
+		}
+	} else {
+		r = <-done
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	actions := []RuleAction{}
+	if r.value == starlark.None {
+		return actions, nil
+	}
+	iterable, ok := r.value.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("%s: check returned %s, want a list of actions", s.Name, r.value.Type())
+	}
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var v starlark.Value
+	for iter.Next(&v) {
+		a, ok := v.(scriptAction)
+		if !ok {
+			return nil, fmt.Errorf("%s: check returned a %s, want an action", s.Name, v.Type())
+		}
+		if err := a.action.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s", s.Name, err.Error())
+		}
+		actions = append(actions, a.action)
+	}
+
+	return PlanActions(actions, issue.IssueFacts), nil
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleScript = `
+SUPPORT = ["alice", "bob"]
+
+def check(issue, repo):
+    actions = []
+    if "panic:" in issue.title.lower() and "bug" not in issue.labels:
+        actions.append(add_label("bug"))
+    if issue.author in SUPPORT:
+        actions.append(add_label("support"))
+        actions.append(assign("carol", "dave"))
+    if issue.inactive_days > 60 and not issue.in_epic:
+        actions.append(comment("Is %s in %s/%s still a problem?" % (issue.tag, repo.org, repo.name)))
+        actions.append(move_card(1527643))
+    if issue.age_days > 365:
+        actions.append(close())
+    if "support" in issue.labels and issue.author not in SUPPORT:
+        actions.append(remove_label("support"))
+    return actions
+`
+
+func TestScript(t *testing.T) {
+	script, err := LoadScript("sample.star", []byte(sampleScript), time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	now := time.Date(2018, 6, 30, 12, 0, 0, 0, time.UTC)
+	issue := ScriptIssue{
+		IssueFacts: IssueFacts{
+			Repo:      "dotmesh",
+			Labels:    []string{"support"},
+			State:     "open",
+			Created:   now.Add(-400 * 24 * time.Hour),
+			Updated:   now.Add(-90 * 24 * time.Hour),
+			Assignees: []string{"dave"},
+		},
+		Number: 12,
+		Title:  "Panic: nil pointer in dm push",
+		Author: "alice",
+	}
+
+	actions, err := script.Check("dotmesh-io", issue, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := "add_label bug; assign carol; comment; move_card to column 1527643; close"
+	if got := describePlan(actions); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if actions[2].Body != "Is dotmesh#12 in dotmesh-io/dotmesh still a problem?" {
+		t.Errorf("Got comment %q", actions[2].Body)
+	}
+
+	issue.Author = "eve"
+	issue.Updated = now
+	issue.Created = now
+	actions, err = script.Check("dotmesh-io", issue, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if got := describePlan(actions); got != "add_label bug; remove_label support" {
+		t.Errorf("Got %q", got)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	for _, bad := range []string{
+		"x = ",
+		"def other(issue, repo):\n    return []",
+		`load("other.star", "x")`,
+		"def check(issue, repo):\n    return 1",
+		"def check(issue, repo):\n    return [\"close\"]",
+		"def check(issue, repo):\n    return [add_label(\"\")]",
+		"def check(issue, repo):\n    return [issue.no_such_field]",
+		"def check(issue, repo):\n    issue.title = \"x\"",
+	} {
+		script, err := LoadScript("bad.star", []byte(bad), 100*time.Millisecond)
+		if err == nil {
+			_, err = script.Check("dotmesh-io", ScriptIssue{}, time.Now())
+		}
+		if err == nil {
+			t.Errorf("Expected an error from %q", bad)
+		} else if strings.TrimSpace(err.Error()) == "" {
+			t.Errorf("Empty error from %q", bad)
+		}
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	// Long enough to outlast the timeout, but short enough that it
+	// doesn't carry on for long after Check gives up on it
+	script, err := LoadScript("slow.star", []byte("def check(issue, repo):\n    for i in range(1000000):\n        pass"), time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	_, err = script.Check("dotmesh-io", ScriptIssue{}, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "took more than") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
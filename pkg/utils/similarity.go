@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Words too common to say anything about what an issue is about
+var stopWords = map[string]struct{}{}
+
+func init() {
+	for _, w := range strings.Fields(`a an and are as at be but by can do does for from has have how i if in into is it its
+		not of on or so that the then there this to too up was we were what when where which while who why will with
+		you your our us me my it's isn't don't doesn't can't should would could just also`) {
+		stopWords[w] = struct{}{}
+	}
+}
+
+// Terms splits some text into lower-case words, leaving out stop words
+// and URLs, and adds each pair of adjacent words ("shingles") so word
+// order counts for something too.
+func Terms(text string) []string {
+	words := []string{}
+	for _, field := range strings.Fields(strings.ToLower(text)) {
+		if strings.Contains(field, "://") {
+			continue
+		}
+		for _, w := range strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+		}) {
+			w = strings.Trim(w, "'")
+			if _, stop := stopWords[w]; stop || len(w) < 2 {
+				continue
+			}
+			words = append(words, w)
+		}
+	}
+
+	terms := append([]string{}, words...)
+	for idx := 1; idx < len(words); idx++ {
+		terms = append(terms, words[idx-1]+" "+words[idx])
+	}
+	return terms
+}
+
+// SimilarityIndex compares documents by the cosine similarity of their
+// TF-IDF vectors, so documents that share unusual words and phrases
+// score highly, and ones that only share common words don't. It all
+// happens in memory.
+type SimilarityIndex struct {
+	ids   []string
+	terms []map[string]int
+
+	// How many documents each term is in
+	documentFrequency map[string]int
+}
+
+func NewSimilarityIndex() *SimilarityIndex {
+	return &SimilarityIndex{
+		documentFrequency: map[string]int{},
+	}
+}
+
+// Add a document, made of the given texts. Give the same text more than
+// once to make it count for more, like a title.
+func (si *SimilarityIndex) Add(id string, texts ...string) {
+	counts := map[string]int{}
+	for _, text := range texts {
+		for _, t := range Terms(text) {
+			counts[t]++
+		}
+	}
+	for t, _ := range counts {
+		si.documentFrequency[t]++
+	}
+	si.ids = append(si.ids, id)
+	si.terms = append(si.terms, counts)
+}
+
+// SimilarPair is two documents and how similar they are, from 0 (nothing
+// in common) to 1 (the same)
+type SimilarPair struct {
+	A, B  string
+	Score float64
+}
+
+// Percent is the pair's score as a whole percentage
+func (p SimilarPair) Percent() int {
+	return int(p.Score*100 + 0.5)
+}
+
+// SimilarPairs returns every pair of documents with a similarity of at
+// least threshold, most similar first.
+func (si *SimilarityIndex) SimilarPairs(threshold float64) []SimilarPair {
+	// Unit-length TF-IDF vectors, and which documents have each term, so
+	// we only compare documents that have something in common
+	vectors := make([]map[string]float64, len(si.terms))
+	postings := map[string][]int{}
+	docs := float64(len(si.terms))
+
+	for idx, counts := range si.terms {
+		vector := map[string]float64{}
+		length := 0.0
+		for t, count := range counts {
+			// Terms in every document don't tell them apart
+			idf := math.Log(docs / float64(si.documentFrequency[t]))
+			if idf <= 0 {
+				continue
+			}
+			weight := (1 + math.Log(float64(count))) * idf
+			vector[t] = weight
+			length += weight * weight
+			postings[t] = append(postings[t], idx)
+		}
+		length = math.Sqrt(length)
+		for t, _ := range vector {
+			vector[t] /= length
+		}
+		vectors[idx] = vector
+	}
+
+	pairs := []SimilarPair{}
+	for a, vector := range vectors {
+		scores := map[int]float64{}
+		for t, weight := range vector {
+			for _, b := range postings[t] {
+				if b > a {
+					scores[b] += weight * vectors[b][t]
+				}
+			}
+		}
+		for b, score := range scores {
+			if score >= threshold {
+				pairs = append(pairs, SimilarPair{A: si.ids[a], B: si.ids[b], Score: score})
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		if pairs[i].A != pairs[j].A {
+			return pairs[i].A < pairs[j].A
+		}
+		return pairs[i].B < pairs[j].B
+	})
+	return pairs
+}
+
+// SimilarClusters groups documents linked by similar pairs, directly or
+// through other documents. Each cluster is sorted, and the clusters are
+// sorted by their first document.
+func SimilarClusters(pairs []SimilarPair) [][]string {
+	parent := map[string]string{}
+	var find func(id string) string
+	find = func(id string) string {
+		p, found := parent[id]
+		if !found || p == id {
+			parent[id] = id
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+
+	for _, p := range pairs {
+		a, b := find(p.A), find(p.B)
+		if a != b {
+			parent[a] = b
+		}
+	}
+
+	groups := map[string][]string{}
+	for id, _ := range parent {
+		root := find(id)
+		groups[root] = append(groups[root], id)
+	}
+
+	clusters := [][]string{}
+	for _, members := range groups {
+		sort.Strings(members)
+		clusters = append(clusters, members)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	got := Terms("The dm push COMMAND hangs (see https://example.com/x), doesn't it?")
+	expected := []string{"dm", "push", "command", "hangs", "see", "dm push", "push command", "command hangs", "hangs see"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestSimilarPairs(t *testing.T) {
+	si := NewSimilarityIndex()
+	si.Add("dotmesh#1", "dm push hangs", "dm push hangs", "When I run dm push to the hub it hangs forever")
+	si.Add("dotmesh#2", "dm push hangs forever", "dm push hangs forever", "Running dm push to the hub, it just hangs")
+	si.Add("dotmesh#3", "Document the S3 remote", "Document the S3 remote", "The docs don't say how to set up an S3 remote")
+	si.Add("janitor#4", "Docs for S3 remotes", "Docs for S3 remotes", "How do I set up an S3 remote? The docs don't say")
+	si.Add("janitor#5", "Crash when starting the operator", "Crash when starting the operator", "The operator panics on startup")
+
+	pairs := si.SimilarPairs(0.3)
+	got := []string{}
+	for _, p := range pairs {
+		got = append(got, p.A+" "+p.B)
+	}
+	expected := []string{"dotmesh#1 dotmesh#2", "dotmesh#3 janitor#4"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q (%v)", expected, got, pairs)
+	}
+
+	if pairs[0].Score > 1.0001 || pairs[0].Score < 0.3 {
+		t.Errorf("Score out of range: %v", pairs[0])
+	}
+
+	if (SimilarPair{Score: 0.666}).Percent() != 67 {
+		t.Errorf("Expected 67%%, got %d%%", (SimilarPair{Score: 0.666}).Percent())
+	}
+
+	if len(NewSimilarityIndex().SimilarPairs(0.3)) != 0 {
+		t.Errorf("An empty index shouldn't have any similar pairs")
+	}
+}
+
+func TestSimilarClusters(t *testing.T) {
+	pairs := []SimilarPair{
+		{A: "c#3", B: "a#1", Score: 0.9},
+		{A: "d#4", B: "e#5", Score: 0.8},
+		{A: "b#2", B: "c#3", Score: 0.7},
+	}
+	expected := [][]string{{"a#1", "b#2", "c#3"}, {"d#4", "e#5"}}
+	got := SimilarClusters(pairs)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
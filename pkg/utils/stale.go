@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"time"
+)
+
+// StaleAction is what should happen next to an issue or pull request
+// that may have gone stale
+type StaleAction int
+
+const (
+	StaleNothing StaleAction = iota
+
+	// Mark it as stale and warn that it'll be closed
+	StaleMark
+
+	// It's been stale for long enough, so close it
+	StaleClose
+
+	// There's been activity since it was marked as stale, so it isn't
+	// any more
+	StaleUnmark
+)
+
+func (a StaleAction) String() string {
+	switch a {
+	case StaleMark:
+		return "mark"
+	case StaleClose:
+		return "close"
+	case StaleUnmark:
+		return "unmark"
+	default:
+		return "nothing"
+	}
+}
+
+// NextStaleAction works out what to do with something whose last
+// activity was at lastActivity. markedAt is when it was marked as
+// stale, or the zero time if it isn't; active says whether there's
+// been activity since then. Things are marked after going quiet for
+// markAfter, and closed once they've been marked for closeAfter. A zero
+// markAfter never marks anything, and a zero closeAfter never closes
+// anything.
+func NextStaleAction(now, lastActivity, markedAt time.Time, active bool, markAfter, closeAfter time.Duration) StaleAction {
+	if !markedAt.IsZero() {
+		switch {
+		case active:
+			return StaleUnmark
+		case closeAfter > 0 && now.Sub(markedAt) >= closeAfter:
+			return StaleClose
+		default:
+			return StaleNothing
+		}
+	}
+
+	if markAfter > 0 && now.Sub(lastActivity) >= markAfter {
+		return StaleMark
+	}
+	return StaleNothing
+}
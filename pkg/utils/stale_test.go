@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextStaleAction(t *testing.T) {
+	now := time.Date(2018, 6, 30, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	never := time.Time{}
+
+	corpus := []struct {
+		name         string
+		lastActivity time.Time
+		markedAt     time.Time
+		active       bool
+		markAfter    time.Duration
+		closeAfter   time.Duration
+		expected     StaleAction
+	}{
+		{"recently active", now.Add(-10 * day), never, false, 30 * day, 7 * day, StaleNothing},
+		{"gone quiet", now.Add(-30 * day), never, false, 30 * day, 7 * day, StaleMark},
+		{"marking disabled", now.Add(-300 * day), never, false, 0, 7 * day, StaleNothing},
+		{"in its grace period", now.Add(-40 * day), now.Add(-6 * day), false, 30 * day, 7 * day, StaleNothing},
+		{"grace period over", now.Add(-40 * day), now.Add(-7 * day), false, 30 * day, 7 * day, StaleClose},
+		{"closing disabled", now.Add(-400 * day), now.Add(-300 * day), false, 30 * day, 0, StaleNothing},
+		{"commented on since being marked", now.Add(-40 * day), now.Add(-10 * day), true, 30 * day, 7 * day, StaleUnmark},
+	}
+
+	for _, c := range corpus {
+		got := NextStaleAction(now, c.lastActivity, c.markedAt, c.active, c.markAfter, c.closeAfter)
+		if got != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"strings"
+)
+
+// TemplateHeadings returns the Markdown headings ("## Steps to
+// reproduce") in an issue template, skipping the front matter at the top
+// that Github uses for the template's name and default labels.
+func TemplateHeadings(template string) []string {
+	lines := strings.Split(strings.Replace(template, "\r\n", "\n", -1), "\n")
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for idx := 1; idx < len(lines); idx++ {
+			if strings.TrimSpace(lines[idx]) == "---" {
+				lines = lines[idx+1:]
+				break
+			}
+		}
+	}
+
+	headings := []string{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			heading := strings.TrimSpace(strings.TrimLeft(line, "#"))
+			if heading != "" {
+				headings = append(headings, heading)
+			}
+		}
+	}
+	return headings
+}
+
+// UsesTemplate guesses whether an issue was written from an issue
+// template, as Github doesn't say: it was if every heading in the
+// template is still in the body. Templates without headings can't be
+// recognised.
+func UsesTemplate(body, template string) bool {
+	headings := TemplateHeadings(template)
+	if len(headings) == 0 {
+		return false
+	}
+
+	found := map[string]struct{}{}
+	for _, heading := range TemplateHeadings(body) {
+		found[strings.ToLower(heading)] = struct{}{}
+	}
+	for _, heading := range headings {
+		if _, ok := found[strings.ToLower(heading)]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+const bugTemplate = `---
+name: Bug report
+about: Something's broken
+labels: bug
+---
+
+## What happened
+
+## What you expected to happen
+
+### Steps to reproduce
+1.
+`
+
+func TestTemplateHeadings(t *testing.T) {
+	expected := []string{"What happened", "What you expected to happen", "Steps to reproduce"}
+	got := TemplateHeadings(bugTemplate)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestUsesTemplate(t *testing.T) {
+	filledIn := "## What happened\r\ndm push hung\r\n\r\n## What you expected to happen\r\nIt pushed\r\n\r\n### steps to reproduce\r\n1. dm push\r\n"
+	if !UsesTemplate(filledIn, bugTemplate) {
+		t.Errorf("Didn't recognise a filled-in template")
+	}
+
+	partly := "## What happened\ndm push hung"
+	if UsesTemplate(partly, bugTemplate) {
+		t.Errorf("Recognised a body with only some of the template's headings")
+	}
+
+	if UsesTemplate("## What happened", "Just some text") {
+		t.Errorf("Recognised a template with no headings")
+	}
+}
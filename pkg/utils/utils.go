@@ -1,27 +1,93 @@
 package utils
 
 import (
-	"regexp"
+	"fmt"
+	"strconv"
+	"strings"
 )
 
+// ParseBodyForIssueLinks finds references to issues and pull requests
+// in org, returning them as "repo#number" strings. Short references
+// like #123 are taken to be to currentRepo. Each issue appears once, in
+// the order it's first mentioned. See ParseIssueRefs for the details.
 func ParseBodyForIssueLinks(body, org, currentRepo string) []string {
 	links := []string{}
 
-	var longLink = regexp.MustCompile(`https://github.com/` + org + `/([^/]+)/issues/([0-9]+)`)
-	var shortLink = regexp.MustCompile(`#([0-9]+)`)
-
-	longLinks := longLink.FindAllStringSubmatch(body, -1)
-	for _, ll := range longLinks {
-		repo := ll[1]
-		issue := ll[2]
-		links = append(links, repo+"#"+issue)
+	for _, ref := range UniqueIssueRefs(ParseIssueRefs(body, org, currentRepo)) {
+		if strings.EqualFold(ref.Owner, org) {
+			links = append(links, ref.Tag())
+		}
 	}
 
-	shortLinks := shortLink.FindAllStringSubmatch(body, -1)
-	for _, sl := range shortLinks {
-		issue := sl[1]
-		links = append(links, currentRepo+"#"+issue)
+	return links
+}
+
+// ParseBodyForOtherOrgLinks finds references to issues and pull
+// requests belonging to orgs other than org, returning them as
+// "org/repo#number" strings, once each and in the order they're first
+// mentioned.
+func ParseBodyForOtherOrgLinks(body, org string) []string {
+	links := []string{}
+
+	for _, ref := range UniqueIssueRefs(ParseIssueRefs(body, org, "")) {
+		if !strings.EqualFold(ref.Owner, org) {
+			links = append(links, ref.String())
+		}
 	}
 
 	return links
 }
+
+// ParseContentURL splits the API URL of an issue or pull request, as
+// found in the ContentURL of a project card, into its parts. URLs look
+// like "https://api.github.com/repos/dotmesh-io/dotmesh/issues/386".
+func ParseContentURL(url string) (org, repo string, number int, err error) {
+	parts := strings.Split(url, "/")
+	if len(parts) < 5 || parts[len(parts)-5] != "repos" {
+		return "", "", 0, fmt.Errorf("unrecognised content URL %q", url)
+	}
+	number, err = strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return "", "", 0, fmt.Errorf("unrecognised content URL %q: %s", url, err.Error())
+	}
+	return parts[len(parts)-4], parts[len(parts)-3], number, nil
+}
+
+// ParseIssueTag splits a "repo#number" string, as returned by
+// ParseBodyForIssueLinks, into its parts.
+func ParseIssueTag(tag string) (repo string, number int, err error) {
+	hashPos := strings.LastIndex(tag, "#")
+	if hashPos < 1 {
+		return "", 0, fmt.Errorf("unrecognised issue tag %q", tag)
+	}
+	number, err = strconv.Atoi(tag[hashPos+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("unrecognised issue tag %q: %s", tag, err.Error())
+	}
+	return tag[:hashPos], number, nil
+}
+
+// RepoNames maps the lower-cased names of an org's repos to their real
+// names. Github doesn't mind what case references to issues are in, so
+// "Dotmesh#5" and "dotmesh#5" are the same issue, but only the second
+// matches the tags we make from Github's own names for repos.
+type RepoNames map[string]string
+
+// Add records the real name of a repo
+func (rn RepoNames) Add(name string) {
+	rn[strings.ToLower(name)] = name
+}
+
+// Tag rewrites a "repo#number" string to use the repo's real name.
+// Repos we don't know about are left as they are.
+func (rn RepoNames) Tag(tag string) string {
+	repo, number, err := ParseIssueTag(tag)
+	if err != nil {
+		return tag
+	}
+	name, known := rn[strings.ToLower(repo)]
+	if !known {
+		return tag
+	}
+	return fmt.Sprintf("%s#%d", name, number)
+}
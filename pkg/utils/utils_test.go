@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"reflect"
 	"sort"
 	"testing"
 )
@@ -33,3 +34,93 @@ Here's the actions we made issues of to make this happen:
 		}
 	}
 }
+
+func TestParseBodyForIssueLinksOrder(t *testing.T) {
+	body := `- [ ] #9 first, then https://github.com/dotmesh-io/dotmesh/issues/2
+- [ ] [#9](https://github.com/dotmesh-io/dotmesh/issues/9) again, and dotmesh-io/janitor#1
+- [x] https://github.com/Dotmesh-IO/dotmesh/issues/2 and kubernetes/kubernetes#5 twice: kubernetes/kubernetes#5`
+
+	expected := []string{"dotmesh#9", "dotmesh#2", "janitor#1"}
+	got := ParseBodyForIssueLinks(body, "dotmesh-io", "dotmesh")
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	others := ParseBodyForOtherOrgLinks(body, "dotmesh-io")
+	if !reflect.DeepEqual(others, []string{"kubernetes/kubernetes#5"}) {
+		t.Errorf("Expected [kubernetes/kubernetes#5], got %v", others)
+	}
+}
+
+func TestRepoNamesTag(t *testing.T) {
+	names := RepoNames{}
+	names.Add("dotmesh")
+	names.Add("GitHub-Issue-Janitor")
+
+	body := "- [ ] Dotmesh-io/Dotmesh#5\n- [ ] https://github.com/Dotmesh-IO/DOTMESH/issues/6\n- [ ] dotmesh-io/github-issue-janitor#7\n- [ ] dotmesh-io/elsewhere#8 and #9"
+	got := []string{}
+	for _, tag := range ParseBodyForIssueLinks(body, "dotmesh-io", "dotmesh") {
+		got = append(got, names.Tag(tag))
+	}
+
+	expected := []string{"dotmesh#5", "dotmesh#6", "GitHub-Issue-Janitor#7", "elsewhere#8", "dotmesh#9"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestParseContentURL(t *testing.T) {
+	org, repo, number, err := ParseContentURL("https://api.github.com/repos/dotmesh-io/dotmesh/issues/386")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if org != "dotmesh-io" || repo != "dotmesh" || number != 386 {
+		t.Errorf("Expected dotmesh-io dotmesh 386, got %s %s %d", org, repo, number)
+	}
+
+	for _, bad := range []string{
+		"",
+		"https://api.github.com/repos/dotmesh-io/dotmesh/issues/banana",
+		"https://github.com/dotmesh-io/dotmesh",
+	} {
+		_, _, _, err := ParseContentURL(bad)
+		if err == nil {
+			t.Errorf("Expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestParseIssueTag(t *testing.T) {
+	repo, number, err := ParseIssueTag("github-issue-janitor#7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if repo != "github-issue-janitor" || number != 7 {
+		t.Errorf("Expected github-issue-janitor 7, got %s %d", repo, number)
+	}
+
+	for _, bad := range []string{"", "#7", "dotmesh", "dotmesh#", "dotmesh#seven"} {
+		_, _, err := ParseIssueTag(bad)
+		if err == nil {
+			t.Errorf("Expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestParseBodyForOtherOrgLinks(t *testing.T) {
+	body := `- [ ] https://github.com/dotmesh-io/dotmesh/issues/3
+- [ ] https://github.com/kubernetes/kubernetes/issues/1234 upstream bug
+- [ ] https://github.com/moby/moby/pull/99 upstream fix
+- [ ] #7`
+
+	expected := []string{"kubernetes/kubernetes#1234", "moby/moby#99"}
+	il := ParseBodyForOtherOrgLinks(body, "dotmesh-io")
+	if len(il) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, il)
+	}
+	for idx, i := range il {
+		if expected[idx] != i {
+			t.Errorf("Expected %v, got %v", expected, il)
+		}
+	}
+}